@@ -48,11 +48,15 @@ func main() {
 	server := server.NewServer()
 
 	c := cron.New()
-	_, err := c.AddFunc("*/10 * * * *", func() { // Run every 10 minutes
-			err := db.UpdateAllFeeds(context.Background())
+	// Every 10 minutes, refresh the sources whose update frequency has elapsed
+	_, err := c.AddFunc("*/10 * * * *", func() {
+			summary, err := db.UpdateAllFeeds(context.Background())
 			if err != nil {
-					log.Printf("Error updating feeds: %v", err) 
+					log.Printf("Error updating feeds: %v", err)
+					return
 			}
+			log.Printf("Feeds updated: %d sources, %d fetched, %d not modified, %d failed, %d new items, %d revised items in %s",
+					summary.Sources, summary.Fetched, summary.NotModified, summary.Failed, summary.NewItems, summary.RevisedItems, summary.Duration)
 	})
 
 	if err != nil {
@@ -61,9 +65,39 @@ func main() {
 	} else {
 			c.Start()
 			defer c.Stop()
-			log.Println("Feed updater started. Running every 10 minutes.")
+			log.Println("Feed updater started. Checking for due feeds every 10 minutes.")
 	} 
 
+	// Every night, delete or archive the feed items past their retention policy
+	_, err = c.AddFunc("30 3 * * *", func() {
+			summary, err := db.PruneFeedItems(context.Background())
+			if err != nil {
+					log.Printf("Error pruning feed items: %v", err)
+					return
+			}
+			log.Printf("Feed items pruned: %d deleted, %d archived", summary.Deleted, summary.Archived)
+	})
+
+	if err != nil {
+			log.Printf("Error scheduling retention job: %v", err)
+	}
+
+	// Every hour, renew the WebSub leases about to expire
+	_, err = c.AddFunc("15 * * * *", func() {
+			renewed, err := db.RenewWebSubSubscriptions(context.Background())
+			if err != nil {
+					log.Printf("Error renewing websub subscriptions: %v", err)
+					return
+			}
+			if renewed > 0 {
+					log.Printf("WebSub subscriptions renewed: %d", renewed)
+			}
+	})
+
+	if err != nil {
+			log.Printf("Error scheduling websub renewal job: %v", err)
+	}
+
 	done := make(chan bool, 1)
 	
 	go gracefulShutdown(server, db, c, done)
@@ -13,6 +13,7 @@ import (
 
 	_ "github.com/joho/godotenv/autoload"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/mmcdole/gofeed"
 )
 
 type Service interface {
@@ -32,11 +33,44 @@ type Service interface {
 
 	CreateFeed(ctx context.Context, source *models.FeedSource, feed *models.Feed, items []*models.FeedItem) error
 	FeedExists(ctx context.Context, link string, userId string) (bool, error)
-	GetFeedItems(ctx context.Context, userId string, order string, limit int, offset int) ([]*models.FeedItemWithFeed, error)
+	GetFeeds(ctx context.Context, userId string) ([]*models.Feed, error)
+	GetFeedSubscriptions(ctx context.Context, userId string) ([]*models.FeedSubscription, error)
+	UpdateFeedSubscription(ctx context.Context, feedLink string, userId string, update *models.FeedSubscriptionUpdate) error
+	GetFeedItems(ctx context.Context, userId string, filter *models.FeedItemsFilter) ([]*models.FeedItemWithFeed, string, error)
 	DeleteFeed(ctx context.Context, link string, userId string) error
+	GetFeedItem(ctx context.Context, id int64, userId string) (*models.FeedItem, error)
 	UpdateFeedItem(ctx context.Context, id int64, userId string, attribute string, value any) error
-	MarkAllFeedItemsAsRead(ctx context.Context, userId string) error
-	UpdateAllFeeds(ctx context.Context) error 
+	SetFeedItemArticle(ctx context.Context, id int64, userId string, articleId string) error
+	MarkFeedItemsAsRead(ctx context.Context, userId string, scope *models.MarkReadScope) (int64, error)
+	GetFeedStats(ctx context.Context, userId string, days int) (*models.FeedStats, error)
+	UpdateFeedFrequency(ctx context.Context, feedLink string, userId string, frequency string) error
+	UpdateAllFeeds(ctx context.Context) (*models.FeedRefreshSummary, error)
+	GetUserRefreshSources(ctx context.Context, userId string, feedLink string) ([]*models.FeedSource, int, error)
+	RefreshFeedSources(ctx context.Context, sources []*models.FeedSource, onRefreshed func()) *models.FeedRefreshSummary
+	DiagnoseFeed(ctx context.Context, userId string, feedLink string) (*models.FeedDiagnostics, error)
+	GetRetentionPolicies(ctx context.Context, userId string) ([]*models.RetentionPolicy, error)
+	UpsertRetentionPolicy(ctx context.Context, policy *models.RetentionPolicy) error
+	DeleteRetentionPolicy(ctx context.Context, userId string, feedLink string) error
+	PruneFeedItems(ctx context.Context) (*models.RetentionSummary, error)
+	GetFeedRules(ctx context.Context, userId string) ([]*models.FeedRule, error)
+	CreateFeedRule(ctx context.Context, rule *models.FeedRule) (*models.FeedRule, error)
+	UpdateFeedRule(ctx context.Context, rule *models.FeedRule) error
+	DeleteFeedRule(ctx context.Context, id int64, userId string) error
+	DryRunFeedRule(ctx context.Context, userId string, rule *models.FeedRule, limit int) ([]*models.FeedRuleMatch, error)
+	GetBrokenFeedSources(ctx context.Context, userId string) ([]*models.FeedSource, error)
+	ReactivateFeedSource(ctx context.Context, feedLink string, userId string) error
+	GetSharedFeeds(ctx context.Context, userId string) ([]*models.SharedFeed, error)
+	GetSharedFeed(ctx context.Context, token string) (*models.SharedFeed, error)
+	CreateSharedFeed(ctx context.Context, feed *models.SharedFeed) error
+	DeleteSharedFeed(ctx context.Context, token string, userId string) error
+	SubscribeWebSub(ctx context.Context, feedLink string, hub string, topic string) error
+	GetWebSubSubscription(ctx context.Context, callbackId string) (*models.WebSubSubscription, error)
+	ConfirmWebSub(ctx context.Context, callbackId string, mode string, topic string, leaseSeconds int) error
+	DenyWebSub(ctx context.Context, callbackId string, topic string, reason string) error
+	IngestWebSubContent(ctx context.Context, feedLink string, feed *gofeed.Feed) (int, error)
+	RenewWebSubSubscriptions(ctx context.Context) (int, error)
+
+	Search(ctx context.Context, userId string, query string, types []string, limit int) (*models.SearchResults, error)
 
 	SaveEmail(ctx context.Context, receivedEmail models.ReceivedEmail) (models.ReceivedEmail, error)
 	GetEmails(ctx context.Context, recipientAlias string) ([]*models.Email, error)
@@ -46,7 +80,8 @@ type Service interface {
 }
 
 type service struct {
-	db *sql.DB
+	db            *sql.DB
+	searchEnabled bool
 }
 
 var (
@@ -79,7 +114,9 @@ func New() Service {
 		return dbInstance
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	// Feeds are refreshed concurrently, so writers wait for the lock instead
+	// of failing right away with SQLITE_BUSY
+	db, err := sql.Open("sqlite3", dbPath+"?_busy_timeout=5000")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -110,6 +147,14 @@ func New() Service {
 		log.Fatal(err)
 	}
 
+	if err := dbInstance.migrate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := dbInstance.initSearch(); err != nil {
+		log.Fatal(err)
+	}
+
 	return dbInstance
 }
 
@@ -2,22 +2,211 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
 	"synthesis/internal/models"
+	fetcher "synthesis/internal/services/feed-fetcher"
 	"time"
 
 	"github.com/mmcdole/gofeed"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	DefaultUpdateFrequency = "1h"
+
+	// The cron job in cmd/api runs every 10 minutes, so shorter frequencies
+	// could never be honored.
+	minUpdateFrequency = 10 * time.Minute
+	maxUpdateFrequency = 30 * 24 * time.Hour
+
+	dueTolerance = time.Minute
+
+	maxFailureBackoff      = 48 * time.Hour
+	defaultMaxFeedFailures = 10
+)
+
+// ParseUpdateFrequency parses a feed update frequency. It accepts anything
+// time.ParseDuration does, plus "d" (days) and "w" (weeks) suffixes such as
+// "1d" or "2w".
+func ParseUpdateFrequency(frequency string) (time.Duration, error) {
+	var (
+		duration time.Duration
+		err      error
+	)
+
+	frequency = strings.TrimSpace(frequency)
+	switch {
+	case strings.HasSuffix(frequency, "d"), strings.HasSuffix(frequency, "w"):
+		unit := 24 * time.Hour
+		if strings.HasSuffix(frequency, "w") {
+			unit = 7 * 24 * time.Hour
+		}
+		var n int
+		n, err = strconv.Atoi(frequency[:len(frequency)-1])
+		duration = time.Duration(n) * unit
+	default:
+		duration, err = time.ParseDuration(frequency)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid update frequency: %q", frequency)
+	}
+	if duration < minUpdateFrequency || duration > maxUpdateFrequency {
+		return 0, fmt.Errorf("update frequency must be between %s and %s", minUpdateFrequency, maxUpdateFrequency)
+	}
+
+	return duration, nil
+}
+
 func (s *service) FeedExists(ctx context.Context, feedLink string, userId string) (bool, error) {
 	var exists bool
-	query := "SELECT EXISTS(SELECT 1 FROM feeds_sources WHERE feed_link = ? AND user_id = ?)"
+	query := "SELECT EXISTS(SELECT 1 FROM feeds WHERE feed_link = ? AND user_id = ?)"
 	err := s.db.QueryRowContext(ctx, query, feedLink, userId).Scan(&exists)
 	return exists, err
 }
 
+func (s *service) GetFeeds(ctx context.Context, userId string) ([]*models.Feed, error) {
+	query := `
+		SELECT feed_link, link, user_id, COALESCE(custom_title, title), description, label, image_url, image_title,
+			updated, updated_parsed, feed_type, created_at, updated_at
+		FROM feeds
+		WHERE user_id = ?
+		ORDER BY COALESCE(custom_title, title)`
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feeds: %w", err)
+	}
+	defer rows.Close()
+
+	var feeds []*models.Feed
+	for rows.Next() {
+		feed := &models.Feed{}
+		err := rows.Scan(
+			&feed.FeedLink, &feed.Link, &feed.UserId, &feed.Title, &feed.Description, &feed.Label,
+			&feed.ImageUrl, &feed.ImageTitle, &feed.Updated, &feed.UpdatedParsed, &feed.FeedType,
+			&feed.CreatedAt, &feed.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan feed: %w", err)
+		}
+		feeds = append(feeds, feed)
+	}
+
+	return feeds, rows.Err()
+}
+
+// GetFeedSubscriptions returns the user's feeds together with the state of
+// their shared source and the number of unread items, leaving out archived
+// ones like the default item listing. A subscription is only active when
+// neither the user paused it nor its source was deactivated.
+func (s *service) GetFeedSubscriptions(ctx context.Context, userId string) ([]*models.FeedSubscription, error) {
+	query := `
+		SELECT
+			f.feed_link, f.link, COALESCE(f.custom_title, f.title), f.description, f.label, f.image_url, f.feed_type,
+			f.update_frequency, fs.last_fetch, f.active AND fs.active, fs.failure_count, fs.last_error, fs.last_error_at, f.extract_content,
+			(SELECT COUNT(*) FROM feeds_items fi WHERE fi.feed_link = f.feed_link AND fi.user_id = f.user_id AND fi.read = FALSE AND fi.archived = FALSE),
+			f.created_at
+		FROM feeds f
+		JOIN feeds_sources fs ON fs.feed_link = f.feed_link
+		WHERE f.user_id = ?
+		ORDER BY COALESCE(f.custom_title, f.title)`
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feed subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := make([]*models.FeedSubscription, 0)
+	for rows.Next() {
+		subscription := &models.FeedSubscription{}
+		err := rows.Scan(
+			&subscription.FeedLink, &subscription.Link, &subscription.Title, &subscription.Description,
+			&subscription.Label, &subscription.ImageUrl, &subscription.FeedType,
+			&subscription.UpdateFrequency, &subscription.LastFetch, &subscription.Active, &subscription.FailureCount,
+			&subscription.LastError, &subscription.LastErrorAt, &subscription.ExtractContent,
+			&subscription.UnreadCount,
+			&subscription.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan feed subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// UpdateFeedSubscription applies the non-nil fields of update to the user's
+// feed. An empty title restores the title published by the feed.
+func (s *service) UpdateFeedSubscription(ctx context.Context, feedLink string, userId string, update *models.FeedSubscriptionUpdate) error {
+	var sets []string
+	var args []any
+	if update.Title != nil {
+		sets = append(sets, "custom_title = ?")
+		args = append(args, nullIfEmpty(strings.TrimSpace(*update.Title)))
+	}
+	if update.Label != nil {
+		sets = append(sets, "label = ?")
+		args = append(args, *update.Label)
+	}
+	if update.Active != nil {
+		sets = append(sets, "active = ?")
+		args = append(args, *update.Active)
+	}
+	if update.UpdateFrequency != nil {
+		if _, err := ParseUpdateFrequency(*update.UpdateFrequency); err != nil {
+			return err
+		}
+		sets = append(sets, "update_frequency = ?")
+		args = append(args, *update.UpdateFrequency)
+	}
+	if update.ExtractContent != nil {
+		sets = append(sets, "extract_content = ?")
+		args = append(args, *update.ExtractContent)
+	}
+
+	// Still run the update without changes so unknown feeds are reported
+	sets = append(sets, "updated_at = ?")
+	query := fmt.Sprintf("UPDATE feeds SET %s WHERE feed_link = ? AND user_id = ?", strings.Join(sets, ", "))
+
+	result, err := s.db.ExecContext(ctx, query, append(args, time.Now(), feedLink, userId)...)
+	if err != nil {
+		return fmt.Errorf("updating feed: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("feed not found: %s", feedLink)
+	}
+
+	return nil
+}
+
+// CreateFeed subscribes source.UserId to the feed. The source is shared with
+// the other subscribers of the same link and only created by the first one,
+// the update frequency, active and extract content settings of source are
+// stored on the user's subscription. The user's rules run over items as they
+// do over the items of a refresh.
 func (s *service) CreateFeed(ctx context.Context, source *models.FeedSource, feed *models.Feed, items []*models.FeedItem) error {
+	compiled, err := s.getCompiledFeedRules(ctx, feed.UserId)
+	if err != nil {
+		return fmt.Errorf("getting feed rules: %w", err)
+	}
+	items = applyFeedRules(compiled, deref(feed.Title), items)
+
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -26,18 +215,18 @@ func (s *service) CreateFeed(ctx context.Context, source *models.FeedSource, fee
 
 	sourceQuery := `
         INSERT INTO feeds_sources (
-            feed_link, link, user_id, update_frequency, active, last_fetch, failure_count, 
-            created_at, updated_at
-        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+            feed_link, link, last_fetch, failure_count,
+            etag, last_modified, created_at, updated_at
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT (feed_link) DO NOTHING`
 
 	_, err = tx.ExecContext(ctx, sourceQuery,
 		source.FeedLink,
 		source.Link,
-		source.UserId,
-		source.UpdateFrequency,
-		source.Active,
 		source.LastFetch,
 		source.FailureCount,
+		source.ETag,
+		source.LastModified,
 		source.CreatedAt,
 		source.UpdatedAt,
 	)
@@ -47,9 +236,9 @@ func (s *service) CreateFeed(ctx context.Context, source *models.FeedSource, fee
 
 	feedQuery := `
         INSERT INTO feeds (
-            feed_link, link, user_id, title, description, label, image_url, image_title, updated, 
-            updated_parsed, feed_type, created_at, updated_at
-        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+            feed_link, link, user_id, title, description, label, image_url, image_title, updated,
+            updated_parsed, feed_type, update_frequency, active, extract_content, created_at, updated_at
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	_, err = tx.ExecContext(ctx, feedQuery,
 		feed.FeedLink,
@@ -63,6 +252,9 @@ func (s *service) CreateFeed(ctx context.Context, source *models.FeedSource, fee
 		feed.Updated,
 		feed.UpdatedParsed,
 		feed.FeedType,
+		source.UpdateFrequency,
+		source.Active,
+		source.ExtractContent,
 		feed.CreatedAt,
 		feed.UpdatedAt,
 	)
@@ -70,79 +262,169 @@ func (s *service) CreateFeed(ctx context.Context, source *models.FeedSource, fee
 		return err
 	}
 
-	itemQuery := `
-        INSERT INTO feeds_items (
-            feed_link, user_id, title, description, content, link, image_url, image_title, published,
-            published_parsed, updated, updated_parsed, guid, read,
-            starred, created_at, updated_at
-        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
+	batch := make(feedItemBatch)
 	for _, item := range items {
-		_, err = tx.ExecContext(ctx, itemQuery,
-			item.FeedLink,
-			item.UserId,
-			item.Title,
-			item.Description,
-			item.Content,
-			item.Link,
-			item.ImageUrl,
-			item.ImageTitle,
-			item.Published,
-			item.PublishedParsed,
-			item.Updated,
-			item.UpdatedParsed,
-			item.GUID,
-			item.Read,
-			item.Starred,
-			item.CreatedAt,
-			item.UpdatedAt,
-		)
+		setFeedItemKeys(item)
+		if !batch.add(item) {
+			continue
+		}
+
+		// The same story may already be stored from another subscription
+		duplicate, err := isDuplicateFeedItem(ctx, tx, item)
 		if err != nil {
 			return err
 		}
+		if duplicate {
+			continue
+		}
+
+		if err := insertFeedItem(ctx, tx, item); err != nil {
+			return err
+		}
 	}
 
 	return tx.Commit()
 }
 
-func (s *service) GetFeedItems(ctx context.Context, userId string, order string, limit int, offset int) ([]*models.FeedItemWithFeed, error) {
-    query := fmt.Sprintf(`
-        SELECT 
-            fi.id, fi.user_id, fi.title, fi.description, fi.content, fi.feed_link, fi.link, 
-            fi.image_url, fi.image_title, fi.published, fi.published_parsed, 
-            fi.updated, fi.updated_parsed, fi.guid, fi.read, fi.starred, 
-            fi.created_at, fi.updated_at,
-            f.title as feed_title, f.description as feed_description, 
-            f.label as feed_label, f.image_url as feed_image_url, f.feed_type  -- Added f.label
-        FROM feeds_items fi
-        JOIN feeds f ON fi.feed_link = f.feed_link AND fi.user_id = f.user_id
-        WHERE fi.user_id = ?
-        ORDER BY fi.published_parsed %s
-        LIMIT ? OFFSET ?`, order)
-
-    rows, err := s.db.QueryContext(ctx, query, userId, limit, offset)
-    if err != nil {
-        return nil, err
-    }
-    defer rows.Close()
-
-    var items []*models.FeedItemWithFeed
-    for rows.Next() {
-        item := &models.FeedItemWithFeed{}
-        err := rows.Scan(
-            &item.Id, &item.UserId, &item.Title, &item.Description, &item.Content, &item.FeedLink, &item.Link,
-            &item.ImageUrl, &item.ImageTitle, &item.Published, &item.PublishedParsed,
-            &item.Updated, &item.UpdatedParsed, &item.GUID, &item.Read, &item.Starred,
-            &item.CreatedAt, &item.UpdatedAt,
-            &item.Feed.Title, &item.Feed.Description, &item.Feed.Label, &item.Feed.ImageUrl, &item.Feed.FeedType, // Added &item.Feed.Label
-        )
-        if err != nil {
-            return nil, err
-        }
-        items = append(items, item)
-    }
-
-    return items, rows.Err()
+// feedItemSortKey orders items by publication date, falling back to the time
+// they were stored for feeds that do not publish dates.
+const feedItemSortKey = "COALESCE(fi.published_parsed, fi.created_at)"
+
+// GetFeedItems returns a page of the user's items matching filter, along with
+// the cursor of the next page, which is empty on the last page. Pages are
+// keyed on (sort key, id) so new items never shift the following pages.
+func (s *service) GetFeedItems(ctx context.Context, userId string, filter *models.FeedItemsFilter) ([]*models.FeedItemWithFeed, string, error) {
+	conditions := []string{"fi.user_id = ?"}
+	args := []any{userId}
+
+	if filter.FeedLink != "" {
+		conditions = append(conditions, "fi.feed_link = ?")
+		args = append(args, filter.FeedLink)
+	}
+	if filter.Label != "" {
+		// Rules can label single items besides the label of their feed
+		conditions = append(conditions, "(f.label = ? OR fi.label = ?)")
+		args = append(args, filter.Label, filter.Label)
+	}
+	if filter.UnreadOnly {
+		conditions = append(conditions, "fi.read = FALSE")
+	}
+	if filter.StarredOnly {
+		conditions = append(conditions, "fi.starred = TRUE")
+	}
+	// Archived items are only listed when explicitly asked for
+	conditions = append(conditions, "fi.archived = ?")
+	args = append(args, filter.Archived)
+	if filter.PublishedAfter != nil {
+		conditions = append(conditions, "julianday("+feedItemSortKey+") >= julianday(?)")
+		args = append(args, sqliteTime(*filter.PublishedAfter))
+	}
+	if filter.PublishedBefore != nil {
+		conditions = append(conditions, "julianday("+feedItemSortKey+") < julianday(?)")
+		args = append(args, sqliteTime(*filter.PublishedBefore))
+	}
+
+	order := "DESC"
+	comparison := "<"
+	if filter.Order == "ASC" {
+		order = "ASC"
+		comparison = ">"
+	}
+
+	offset := filter.Offset
+	if filter.Cursor != "" {
+		sortKey, id, err := decodeFeedItemsCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND fi.id %[2]s ?))", feedItemSortKey, comparison))
+		args = append(args, sortKey, sortKey, id)
+		offset = 0
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			fi.id, fi.user_id, fi.title, fi.description, fi.content, fi.feed_link, fi.link, fi.author, fi.authors,
+			fi.categories, fi.enclosures, fi.label, fi.image_url, fi.image_title, fi.published, fi.published_parsed,
+			fi.updated, fi.updated_parsed, fi.guid, fi.read, fi.starred, fi.article_id, fi.revised, fi.revised_at,
+			fi.created_at, fi.updated_at,
+			COALESCE(f.custom_title, f.title) as feed_title, f.description as feed_description,
+			f.label as feed_label, f.image_url as feed_image_url, f.feed_type,
+			%[1]s as sort_key
+		FROM feeds_items fi
+		JOIN feeds f ON fi.feed_link = f.feed_link AND fi.user_id = f.user_id
+		WHERE %[2]s
+		ORDER BY sort_key %[3]s, fi.id %[3]s
+		LIMIT ? OFFSET ?`, feedItemSortKey, strings.Join(conditions, " AND "), order)
+
+	args = append(args, filter.Limit, offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var (
+		items       []*models.FeedItemWithFeed
+		lastSortKey string
+	)
+	for rows.Next() {
+		item := &models.FeedItemWithFeed{}
+		err := rows.Scan(
+			&item.Id, &item.UserId, &item.Title, &item.Description, &item.Content, &item.FeedLink, &item.Link,
+			&item.Author, scanJSON(&item.Authors), scanJSON(&item.Categories), scanJSON(&item.Enclosures), &item.Label,
+			&item.ImageUrl, &item.ImageTitle, &item.Published, &item.PublishedParsed, &item.Updated,
+			&item.UpdatedParsed, &item.GUID, &item.Read, &item.Starred, &item.ArticleId, &item.Revised,
+			&item.RevisedAt, &item.CreatedAt, &item.UpdatedAt,
+			&item.Feed.Title, &item.Feed.Description, &item.Feed.Label, &item.Feed.ImageUrl, &item.Feed.FeedType,
+			&lastSortKey,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var nextCursor string
+	if len(items) == filter.Limit {
+		nextCursor = encodeFeedItemsCursor(lastSortKey, items[len(items)-1].Id)
+	}
+
+	return items, nextCursor, nil
+}
+
+// The cursor carries the raw stored sort key, so comparisons happen on the
+// exact same text SQLite ordered by.
+func encodeFeedItemsCursor(sortKey string, id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(sortKey + "|" + strconv.FormatInt(id, 10)))
+}
+
+func decodeFeedItemsCursor(cursor string) (string, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+
+	sep := strings.LastIndex(string(raw), "|")
+	if sep < 0 {
+		return "", 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(string(raw[sep+1:]), 10, 64)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+
+	return string(raw[:sep]), id, nil
+}
+
+// sqliteTime formats t the way SQLite date functions expect it.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
 }
 
 func (s *service) DeleteFeed(ctx context.Context, feedLink string, userId string) error {
@@ -164,18 +446,17 @@ func (s *service) DeleteFeed(ctx context.Context, feedLink string, userId string
 		return fmt.Errorf("deleting feed items: %w", err)
 	}
 
-	// 2. Delete Feeds:
-	_, err = tx.ExecContext(ctx, "DELETE FROM feeds WHERE feed_link = ? AND user_id = ?", feedLink, userId)
+	_, err = tx.ExecContext(ctx, "DELETE FROM feeds_items_pruned WHERE feed_link = ? AND user_id = ?", feedLink, userId)
 	if err != nil {
 		tx.Rollback() // Rollback on error
-		return fmt.Errorf("deleting feeds: %w", err)
+		return fmt.Errorf("deleting pruned feed items: %w", err)
 	}
 
-	// 3. Delete Feed Source:
-	result, err := tx.ExecContext(ctx, "DELETE FROM feeds_sources WHERE feed_link = ? AND user_id = ?", feedLink, userId)
+	// 2. Delete Feeds:
+	result, err := tx.ExecContext(ctx, "DELETE FROM feeds WHERE feed_link = ? AND user_id = ?", feedLink, userId)
 	if err != nil {
 		tx.Rollback() // Rollback on error
-		return fmt.Errorf("deleting feed source: %w", err)
+		return fmt.Errorf("deleting feeds: %w", err)
 	}
 
 	rows, err := result.RowsAffected()
@@ -184,14 +465,50 @@ func (s *service) DeleteFeed(ctx context.Context, feedLink string, userId string
 		return fmt.Errorf("getting affected rows: %w", err)
 	}
 	if rows == 0 {
-		tx.Rollback() // Rollback if feed source not found
+		tx.Rollback() // Rollback if feed not found
 		return fmt.Errorf("feed source not found: %s", feedLink)
 	}
 
+	// 3. Delete Feed Source once its last subscriber is gone:
+	result, err = tx.ExecContext(ctx, "DELETE FROM feeds_sources WHERE feed_link = ? AND NOT EXISTS (SELECT 1 FROM feeds WHERE feed_link = ?)", feedLink, feedLink)
+	if err != nil {
+		tx.Rollback() // Rollback on error
+		return fmt.Errorf("deleting feed source: %w", err)
+	}
+
+	sourceRows, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback() // Rollback on error
+		return fmt.Errorf("getting affected rows: %w", err)
+	}
+
+	// 4. Stop the hub from pushing a source that is gone:
+	var sub *models.WebSubSubscription
+	if sourceRows > 0 {
+		sub, err = s.getWebSubSubscription(ctx, tx, "feed_link", feedLink)
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			tx.Rollback() // Rollback on error
+			return err
+		}
+		err = nil
+		if sub != nil {
+			_, err = tx.ExecContext(ctx, "UPDATE feeds_websub SET state = ?, updated_at = ? WHERE feed_link = ?", webSubUnsubscribing, time.Now(), feedLink)
+			if err != nil {
+				tx.Rollback() // Rollback on error
+				return fmt.Errorf("updating websub subscription: %w", err)
+			}
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("committing transaction: %w", err)
 	}
 
+	if sub != nil {
+		sub.State = webSubUnsubscribing
+		go s.unsubscribeWebSub(context.WithoutCancel(ctx), sub)
+	}
+
 	return nil
 }
 
@@ -201,8 +518,22 @@ func (s *service) UpdateFeedItem(ctx context.Context, id int64, userId string, a
 		SET %s = ?
 		WHERE id = ? AND user_id = ?
 	`, attribute)
+	args := []any{value, id, userId}
+
+	// Reading statistics need the time an item was first read one by one,
+	// opening an item a bulk mark caught counts as reading it
+	if attribute == "read" {
+		query = `
+			UPDATE feeds_items
+			SET read = ?,
+				read_at = CASE WHEN NOT ? THEN NULL WHEN read_in_bulk THEN ? ELSE COALESCE(read_at, ?) END,
+				read_in_bulk = FALSE
+			WHERE id = ? AND user_id = ?`
+		now := time.Now()
+		args = []any{value, value, now, now, id, userId}
+	}
 
-	_, err := s.db.ExecContext(ctx, query, value, id, userId)
+	_, err := s.db.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update post: %w", err)
 	}
@@ -210,15 +541,42 @@ func (s *service) UpdateFeedItem(ctx context.Context, id int64, userId string, a
 	return nil
 }
 
-func (s *service) MarkAllFeedItemsAsRead(ctx context.Context, userId string) error {
+func (s *service) GetFeedItem(ctx context.Context, id int64, userId string) (*models.FeedItem, error) {
+	query := `
+		SELECT id, user_id, title, description, content, feed_link, link, author, authors, categories, enclosures, label,
+			image_url, image_title, published, published_parsed, updated, updated_parsed, guid, read, starred,
+			article_id, revised, revised_at, created_at, updated_at
+		FROM feeds_items
+		WHERE id = ? AND user_id = ?`
+
+	item := &models.FeedItem{}
+	err := s.db.QueryRowContext(ctx, query, id, userId).Scan(
+		&item.Id, &item.UserId, &item.Title, &item.Description, &item.Content, &item.FeedLink, &item.Link,
+		&item.Author, scanJSON(&item.Authors), scanJSON(&item.Categories), scanJSON(&item.Enclosures), &item.Label,
+		&item.ImageUrl, &item.ImageTitle, &item.Published, &item.PublishedParsed, &item.Updated,
+		&item.UpdatedParsed, &item.GUID, &item.Read, &item.Starred, &item.ArticleId, &item.Revised,
+		&item.RevisedAt, &item.CreatedAt, &item.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("feed item not found: %v", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed item: %w", err)
+	}
+
+	return item, nil
+}
+
+// SetFeedItemArticle links a feed item to the article it was saved as.
+func (s *service) SetFeedItemArticle(ctx context.Context, id int64, userId string, articleId string) error {
 	query := `
 		UPDATE feeds_items
-		SET read = TRUE
-		WHERE user_id = ?`
+		SET article_id = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?`
 
-	result, err := s.db.ExecContext(ctx, query, userId)
+	result, err := s.db.ExecContext(ctx, query, articleId, time.Now(), id, userId)
 	if err != nil {
-		return fmt.Errorf("failed to update feeds: %w", err)
+		return fmt.Errorf("failed to link feed item to article: %w", err)
 	}
 
 	rows, err := result.RowsAffected()
@@ -226,30 +584,137 @@ func (s *service) MarkAllFeedItemsAsRead(ctx context.Context, userId string) err
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 	if rows == 0 {
-		return fmt.Errorf("feeds not found")
+		return fmt.Errorf("feed item not found: %v", id)
 	}
 
 	return nil
 }
 
-func (s *service) UpdateAllFeeds(ctx context.Context) error {
-	sources, err := s.getAllActiveFeedSources(ctx)
+// MarkFeedItemsAsRead marks the user's unread items matching every set field
+// of scope as read, and returns how many items changed. An empty scope
+// targets all of the user's items. Unless scope only lists ids, the items are
+// flagged as read in bulk so reading statistics leave them out.
+func (s *service) MarkFeedItemsAsRead(ctx context.Context, userId string, scope *models.MarkReadScope) (int64, error) {
+	conditions := []string{"user_id = ?", "read = FALSE"}
+	args := []any{userId}
+
+	if scope.FeedLink != "" {
+		conditions = append(conditions, "feed_link = ?")
+		args = append(args, scope.FeedLink)
+	}
+	if scope.Label != "" {
+		conditions = append(conditions, "(feed_link IN (SELECT feed_link FROM feeds WHERE user_id = ? AND label = ?) OR label = ?)")
+		args = append(args, userId, scope.Label, scope.Label)
+	}
+	if len(scope.Ids) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(scope.Ids)), ", ")
+		conditions = append(conditions, "id IN ("+placeholders+")")
+		for _, id := range scope.Ids {
+			args = append(args, id)
+		}
+	}
+	if scope.OlderThan != nil {
+		conditions = append(conditions, "julianday(COALESCE(published_parsed, created_at)) < julianday(?)")
+		args = append(args, sqliteTime(*scope.OlderThan))
+	}
+
+	bulk := scope.FeedLink != "" || scope.Label != "" || scope.OlderThan != nil || len(scope.Ids) == 0
+
+	query := `
+		UPDATE feeds_items
+		SET read = TRUE, read_at = ?, read_in_bulk = ?
+		WHERE ` + strings.Join(conditions, " AND ")
+
+	result, err := s.db.ExecContext(ctx, query, append([]any{time.Now(), bulk}, args...)...)
 	if err != nil {
-		return fmt.Errorf("getting active feed sources: %w", err)
+		return 0, fmt.Errorf("failed to update feeds: %w", err)
 	}
 
-	for _, source := range sources {
-		err := s.updateFeed(ctx, source)
-		if err != nil {
-			log.Printf("Error updating feed %s: %v", source.FeedLink, err)
-		}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
+
+func (s *service) UpdateFeedFrequency(ctx context.Context, feedLink string, userId string, frequency string) error {
+	if _, err := ParseUpdateFrequency(frequency); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE feeds
+		SET update_frequency = ?, updated_at = ?
+		WHERE feed_link = ? AND user_id = ?`
+
+	result, err := s.db.ExecContext(ctx, query, frequency, time.Now(), feedLink, userId)
+	if err != nil {
+		return fmt.Errorf("failed to update feed frequency: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("feed source not found: %s", feedLink)
 	}
+
 	return nil
 }
 
+const feedSourceColumns = `fs.feed_link, fs.link, fs.last_fetch, fs.active, fs.failure_count, fs.etag, fs.last_modified, fs.last_error, fs.last_error_at, fs.created_at, fs.updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanFeedSource scans feedSourceColumns followed by the extra columns of
+// the query into extra.
+func scanFeedSource(row rowScanner, extra ...any) (*models.FeedSource, error) {
+	source := &models.FeedSource{}
+	dest := []any{
+		&source.FeedLink, &source.Link, &source.LastFetch, &source.Active,
+		&source.FailureCount, &source.ETag, &source.LastModified, &source.LastError, &source.LastErrorAt,
+		&source.CreatedAt, &source.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// getAllActiveFeedSources returns the active sources that have at least one
+// active subscription. A source is fetched as often as its most frequent
+// subscriber asks, and extracts content when any subscriber wants it.
 func (s *service) getAllActiveFeedSources(ctx context.Context) ([]*models.FeedSource, error) {
-	query := `SELECT feed_link, link, user_id, update_frequency, last_fetch, active, failure_count, created_at, updated_at FROM feeds_sources WHERE active = TRUE`
-	rows, err := s.db.QueryContext(ctx, query)
+	return s.queryActiveFeedSources(ctx, "")
+}
+
+// getUserFeedSources returns the active sources of the user's active
+// subscriptions, or only the one of feedLink when it is set. They are set up
+// for every subscriber, like the ones of getAllActiveFeedSources.
+func (s *service) getUserFeedSources(ctx context.Context, userId string, feedLink string) ([]*models.FeedSource, error) {
+	condition := "AND fs.feed_link IN (SELECT feed_link FROM feeds WHERE user_id = ? AND active = TRUE)"
+	args := []any{userId}
+	if feedLink != "" {
+		condition += " AND fs.feed_link = ?"
+		args = append(args, feedLink)
+	}
+
+	return s.queryActiveFeedSources(ctx, condition, args...)
+}
+
+func (s *service) queryActiveFeedSources(ctx context.Context, condition string, args ...any) ([]*models.FeedSource, error) {
+	query := `
+		SELECT ` + feedSourceColumns + `, GROUP_CONCAT(f.update_frequency, ','), MAX(f.extract_content)
+		FROM feeds_sources fs
+		JOIN feeds f ON f.feed_link = fs.feed_link AND f.active = TRUE
+		WHERE fs.active = TRUE ` + condition + `
+		GROUP BY fs.feed_link`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -257,129 +722,460 @@ func (s *service) getAllActiveFeedSources(ctx context.Context) ([]*models.FeedSo
 
 	var sources []*models.FeedSource
 	for rows.Next() {
-		source := &models.FeedSource{}
-		err := rows.Scan(&source.FeedLink, &source.Link, &source.UserId, &source.UpdateFrequency, &source.LastFetch, &source.Active, &source.FailureCount, &source.CreatedAt, &source.UpdatedAt)
+		var frequencies string
+		var extractContent bool
+		source, err := scanFeedSource(rows, &frequencies, &extractContent)
 		if err != nil {
 			return nil, err
 		}
+		source.UpdateFrequency = shortestUpdateFrequency(strings.Split(frequencies, ","))
+		source.ExtractContent = extractContent
 		sources = append(sources, source)
 	}
 	return sources, rows.Err()
 }
 
-func (s *service) updateFeed(ctx context.Context, source *models.FeedSource) error {
-    fp := gofeed.NewParser()
-    feed, err := fp.ParseURLWithContext(source.FeedLink, ctx)
-    if err != nil {
-        _, errUpdate := s.db.ExecContext(ctx, "UPDATE feeds_sources SET failure_count = failure_count + 1, updated_at = ? WHERE feed_link = ?", time.Now(), source.FeedLink)
-        if errUpdate != nil {
-            return fmt.Errorf("parsing feed %w, updating failure count %w", err, errUpdate)
-        }
-        return fmt.Errorf("parsing feed: %w", err)
-    }
-
-    tx, err := s.db.BeginTx(ctx, nil)
-    if err != nil {
-        return fmt.Errorf("starting transaction: %w", err)
-    }
-    defer tx.Rollback()
-
-    _, err = tx.ExecContext(ctx, "UPDATE feeds_sources SET last_fetch = ?, updated_at = ?, failure_count = 0 WHERE feed_link = ?", time.Now(), time.Now(), source.FeedLink)
-    if err != nil {
-        return fmt.Errorf("updating feed source: %w", err)
-    }
-
-    if feed != nil && feed.Image != nil && feed.Image.URL != "" {
-        _, err = tx.ExecContext(ctx, "UPDATE feeds SET title = ?, description = ?, updated = ?, updated_parsed = ?, image_url = ?, image_title = ?, updated_at = ? WHERE feed_link = ?",
-            feed.Title, feed.Description, feed.Updated, feed.UpdatedParsed, feed.Image.URL, feed.Image.Title, time.Now(), source.FeedLink)
-        if err != nil {
-            return fmt.Errorf("updating feed: %w", err)
-        }
-    } else if feed != nil {
-         _, err = tx.ExecContext(ctx, "UPDATE feeds SET title = ?, description = ?, updated = ?, updated_parsed = ?, updated_at = ? WHERE feed_link = ?",
-            feed.Title, feed.Description, feed.Updated, feed.UpdatedParsed, time.Now(), source.FeedLink)
-        if err != nil {
-            return fmt.Errorf("updating feed: %w", err)
-        }
-    }
-
-
-    if feed != nil {
-        for _, item := range feed.Items {
-            exists, err := s.feedItemExists(ctx, &item.GUID, source.FeedLink)
-            if err != nil {
-                return fmt.Errorf("checking if feed item exists: %w", err)
-            }
-            if !exists {
-                feedItem := &models.FeedItem{
-                    UserId:          source.UserId,
-                    Title:           &item.Title,
-                    Description:     &item.Description,
-                    Content:         &item.Content,
-                    FeedLink:        source.FeedLink,
-                    Link:            &item.Link,
-                    Published:       &item.Published,
-                    PublishedParsed: item.PublishedParsed,
-                    Updated:         &item.Updated,
-                    UpdatedParsed:   item.UpdatedParsed,
-                    GUID:            &item.GUID,
-                    Read:            false,
-                    Starred:         false,
-                    CreatedAt:       time.Now(),
-                    UpdatedAt:       time.Now(),
-                }
-
-                if item.Image != nil && item.Image.URL != "" {
-                    feedItem.ImageUrl = &item.Image.URL
-                    if item.Image.Title != "" {
-                        feedItem.ImageTitle = &item.Image.Title
-                    }
-                }
-
-                itemQuery := `
-                    INSERT INTO feeds_items (
-                        feed_link, user_id, title, description, content, link, image_url, image_title, published,
-                        published_parsed, updated, updated_parsed, guid, read,
-                        starred, created_at, updated_at
-                    ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
-                _, err = tx.ExecContext(ctx, itemQuery,
-                    feedItem.FeedLink,
-                    feedItem.UserId,
-                    feedItem.Title,
-                    feedItem.Description,
-                    feedItem.Content,
-                    feedItem.Link,
-                    feedItem.ImageUrl,
-                    feedItem.ImageTitle,
-                    feedItem.Published,
-                    feedItem.PublishedParsed,
-                    feedItem.Updated,
-                    feedItem.UpdatedParsed,
-                    feedItem.GUID,
-                    feedItem.Read,
-                    feedItem.Starred,
-                    feedItem.CreatedAt,
-                    feedItem.UpdatedAt,
-                )
-                if err != nil {
-                    return fmt.Errorf("inserting feed item: %w", err)
-                }
-            }
-        }
-    }
-
-    return tx.Commit()
-}
-
-func (s *service) feedItemExists(ctx context.Context, guid *string, feedLink string) (bool, error) {
-	if guid == nil {
-		return false, nil
+// shortestUpdateFrequency returns the most frequent of frequencies, ignoring
+// invalid ones.
+func shortestUpdateFrequency(frequencies []string) string {
+	shortest := DefaultUpdateFrequency
+	var shortestDuration time.Duration
+	for _, frequency := range frequencies {
+		duration, err := ParseUpdateFrequency(frequency)
+		if err != nil {
+			continue
+		}
+		if shortestDuration == 0 || duration < shortestDuration {
+			shortest, shortestDuration = frequency, duration
+		}
+	}
+	return shortest
+}
+
+// GetBrokenFeedSources returns the sources of the user's feeds that failed
+// their last fetch, including the ones that were deactivated after too many
+// failures. Their frequency and settings are the user's own.
+func (s *service) GetBrokenFeedSources(ctx context.Context, userId string) ([]*models.FeedSource, error) {
+	query := `
+		SELECT ` + feedSourceColumns + `, f.user_id, f.update_frequency, f.extract_content
+		FROM feeds_sources fs
+		JOIN feeds f ON f.feed_link = fs.feed_link AND f.user_id = ?
+		WHERE fs.failure_count > 0
+		ORDER BY fs.last_error_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feed sources: %w", err)
 	}
-	var exists bool
+	defer rows.Close()
+
+	var sources []*models.FeedSource
+	for rows.Next() {
+		var user, frequency string
+		var extractContent bool
+		source, err := scanFeedSource(rows, &user, &frequency, &extractContent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan feed source: %w", err)
+		}
+		source.UserId, source.UpdateFrequency, source.ExtractContent = user, frequency, extractContent
+		sources = append(sources, source)
+	}
+	return sources, rows.Err()
+}
 
-	query := "SELECT EXISTS(SELECT 1 FROM feeds_items WHERE guid = ? AND feed_link = ?)"
-	err := s.db.QueryRowContext(ctx, query, *guid, feedLink).Scan(&exists)
+// ReactivateFeedSource clears the failure state of a source and makes it due
+// again on the next refresh.
+func (s *service) ReactivateFeedSource(ctx context.Context, feedLink string, userId string) error {
+	query := `
+		UPDATE feeds_sources
+		SET active = TRUE, failure_count = 0, last_error = NULL, last_error_at = NULL, updated_at = ?
+		WHERE feed_link = ? AND EXISTS (SELECT 1 FROM feeds WHERE feed_link = ? AND user_id = ?)`
 
-	return exists, err
+	result, err := s.db.ExecContext(ctx, query, time.Now(), feedLink, feedLink, userId)
+	if err != nil {
+		return fmt.Errorf("failed to reactivate feed source: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("feed source not found: %s", feedLink)
+	}
+
+	return nil
+}
+
+// recordFeedFailure bumps the failure count of a source and deactivates it
+// once it reaches maxFeedFailures.
+func (s *service) recordFeedFailure(ctx context.Context, feedLink string, fetchErr error) error {
+	query := `
+		UPDATE feeds_sources
+		SET failure_count = failure_count + 1,
+			last_error = ?,
+			last_error_at = ?,
+			active = CASE WHEN failure_count + 1 >= ? THEN FALSE ELSE active END,
+			updated_at = ?
+		WHERE feed_link = ?`
+
+	now := time.Now()
+	_, err := s.db.ExecContext(ctx, query, fetchErr.Error(), now, maxFeedFailures(), now, feedLink)
+	return err
+}
+
+func (s *service) getDueFeedSources(ctx context.Context, now time.Time) ([]*models.FeedSource, error) {
+	sources, err := s.getAllActiveFeedSources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var due []*models.FeedSource
+	for _, source := range sources {
+		if isFeedSourceDue(source, now) {
+			due = append(due, source)
+		}
+	}
+	return due, nil
+}
+
+// isFeedSourceDue reports whether a source should be fetched at now. Sources
+// with an unparseable frequency fall back to DefaultUpdateFrequency. The
+// tolerance keeps a fetch that ran a few seconds after a cron tick from being
+// pushed back a whole interval.
+func isFeedSourceDue(source *models.FeedSource, now time.Time) bool {
+	frequency := feedSourceFrequency(source)
+
+	if !source.LastFetch.Add(frequency).After(now.Add(dueTolerance)) {
+		return !isFeedSourceBackingOff(source, now.Add(dueTolerance))
+	}
+
+	return false
+}
+
+// isFeedSourceBackingOff reports whether a failing source is still waiting
+// out its failureBackoff at now.
+func isFeedSourceBackingOff(source *models.FeedSource, now time.Time) bool {
+	if source.FailureCount == 0 || source.LastErrorAt == nil {
+		return false
+	}
+	return source.LastErrorAt.Add(failureBackoff(feedSourceFrequency(source), source.FailureCount)).After(now)
+}
+
+func feedSourceFrequency(source *models.FeedSource) time.Duration {
+	frequency, err := ParseUpdateFrequency(source.UpdateFrequency)
+	if err != nil {
+		frequency, _ = ParseUpdateFrequency(DefaultUpdateFrequency)
+	}
+	return frequency
+}
+
+// failureBackoff doubles the wait after every consecutive failure, starting
+// from the source's own frequency and capped at maxFailureBackoff.
+func failureBackoff(frequency time.Duration, failures int) time.Duration {
+	backoff := frequency
+	for i := 1; i < failures && backoff < maxFailureBackoff; i++ {
+		backoff *= 2
+	}
+
+	return max(frequency, min(backoff, maxFailureBackoff))
+}
+
+// maxFeedFailures is the number of consecutive failures after which a source
+// is deactivated, configurable through FEED_MAX_FAILURES.
+func maxFeedFailures() int {
+	if n, err := strconv.Atoi(os.Getenv("FEED_MAX_FAILURES")); err == nil && n > 0 {
+		return n
+	}
+	return defaultMaxFeedFailures
+}
+
+// feedUpdateResult describes what a single updateFeed call did.
+type feedUpdateResult struct {
+	notModified  bool
+	newItems     int
+	revisedItems int
+	// URL the feed permanently moved to, for the caller to migrate the
+	// source once it holds no fetch slot
+	movedTo string
+}
+
+func (s *service) updateFeed(ctx context.Context, source *models.FeedSource) (*feedUpdateResult, error) {
+	var etag, lastModified string
+	if source.ETag != nil {
+		etag = *source.ETag
+	}
+	if source.LastModified != nil {
+		lastModified = *source.LastModified
+	}
+
+	result, err := fetcher.Fetch(ctx, source.FeedLink, etag, lastModified)
+	if err != nil {
+		// The fetch context may already be expired, which must not prevent
+		// the failure from being recorded
+		errUpdate := s.recordFeedFailure(context.WithoutCancel(ctx), source.FeedLink, err)
+		if errUpdate != nil {
+			return nil, fmt.Errorf("parsing feed %w, updating failure count %w", err, errUpdate)
+		}
+		return nil, fmt.Errorf("parsing feed: %w", err)
+	}
+
+	// After a permanent redirect the hub is discovered again under the new
+	// URL, once the source moved there
+	if result.Hub != "" && result.PermanentRedirect == "" {
+		s.discoverWebSubHub(ctx, source.FeedLink, result.Hub, result.Self)
+	}
+
+	update, err := s.storeFeedUpdate(ctx, source, result)
+	if err != nil {
+		return nil, err
+	}
+	update.movedTo = result.PermanentRedirect
+
+	return update, nil
+}
+
+// storeFeedUpdate stores a fetched or pushed version of the source's feed.
+// The feed is fetched once and its new items fanned out to every active
+// subscriber, each with their own deduplication and retention.
+func (s *service) storeFeedUpdate(ctx context.Context, source *models.FeedSource, result *fetcher.Result) (*feedUpdateResult, error) {
+	var newItems, revisedItems, extractItems []*models.FeedItem
+	if !result.NotModified && result.Feed != nil {
+		subscribers, err := s.getFeedSubscribers(ctx, source.FeedLink)
+		if err != nil {
+			return nil, fmt.Errorf("getting feed subscribers: %w", err)
+		}
+
+		parsed := parseFeedItems(result.Feed, source.FeedLink)
+		for _, subscriber := range subscribers {
+			items, revised, err := s.newFeedItems(ctx, source, subscriber.userId, parsed)
+			if err != nil {
+				return nil, err
+			}
+
+			compiled, err := s.getCompiledFeedRules(ctx, subscriber.userId)
+			if err != nil {
+				return nil, fmt.Errorf("getting feed rules: %w", err)
+			}
+			items = applyFeedRules(compiled, subscriber.feedTitle, items)
+			newItems = append(newItems, items...)
+			revisedItems = append(revisedItems, revised...)
+			if subscriber.extractContent {
+				extractItems = append(extractItems, items...)
+				extractItems = append(extractItems, revised...)
+			}
+		}
+	}
+
+	// Scraping happens before the transaction so slow article hosts never
+	// hold the database lock
+	if len(extractItems) > 0 {
+		extractContent(ctx, extractItems)
+	}
+	if pageImagesEnabled() && len(newItems) > 0 {
+		fetchPageImages(ctx, newItems)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "UPDATE feeds_sources SET last_fetch = ?, updated_at = ?, failure_count = 0, last_error = NULL, last_error_at = NULL, etag = ?, last_modified = ? WHERE feed_link = ?",
+		time.Now(), time.Now(), nullIfEmpty(result.ETag), nullIfEmpty(result.LastModified), source.FeedLink)
+	if err != nil {
+		return nil, fmt.Errorf("updating feed source: %w", err)
+	}
+
+	// Nothing changed upstream, the fetch only refreshes last_fetch
+	if result.NotModified {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("committing transaction: %w", err)
+		}
+		return &feedUpdateResult{notModified: true}, nil
+	}
+
+	feed := result.Feed
+	update := &feedUpdateResult{}
+
+	if feed != nil && feed.Image != nil && feed.Image.URL != "" {
+		_, err = tx.ExecContext(ctx, "UPDATE feeds SET title = ?, description = ?, updated = ?, updated_parsed = ?, image_url = ?, image_title = ?, updated_at = ? WHERE feed_link = ?",
+			feed.Title, feed.Description, feed.Updated, feed.UpdatedParsed, feed.Image.URL, feed.Image.Title, time.Now(), source.FeedLink)
+		if err != nil {
+			return nil, fmt.Errorf("updating feed: %w", err)
+		}
+	} else if feed != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE feeds SET title = ?, description = ?, updated = ?, updated_parsed = ?, updated_at = ? WHERE feed_link = ?",
+			feed.Title, feed.Description, feed.Updated, feed.UpdatedParsed, time.Now(), source.FeedLink)
+		if err != nil {
+			return nil, fmt.Errorf("updating feed: %w", err)
+		}
+	}
+
+	for _, feedItem := range newItems {
+		if err := insertFeedItem(ctx, tx, feedItem); err != nil {
+			return nil, fmt.Errorf("inserting feed item: %w", err)
+		}
+		update.newItems++
+	}
+
+	for _, feedItem := range revisedItems {
+		if err := updateRevisedFeedItem(ctx, tx, feedItem); err != nil {
+			return nil, fmt.Errorf("updating revised feed item: %w", err)
+		}
+		update.revisedItems++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return update, nil
+}
+
+type feedSubscriber struct {
+	userId         string
+	extractContent bool
+	// Title the user sees the feed under, which "feed" rules match against
+	feedTitle string
+}
+
+// getFeedSubscribers returns the users with an active subscription to the
+// feed, paused subscriptions do not receive new items.
+func (s *service) getFeedSubscribers(ctx context.Context, feedLink string) ([]feedSubscriber, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT user_id, extract_content, COALESCE(custom_title, title, '') FROM feeds WHERE feed_link = ? AND active = TRUE", feedLink)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscribers []feedSubscriber
+	for rows.Next() {
+		var subscriber feedSubscriber
+		if err := rows.Scan(&subscriber.userId, &subscriber.extractContent, &subscriber.feedTitle); err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, subscriber)
+	}
+	return subscribers, rows.Err()
+}
+
+// parseFeedItems converts the items of the feed at feedLink once, keyed for
+// deduplication, so they can be copied to every subscriber without parsing
+// their content again.
+func parseFeedItems(feed *gofeed.Feed, feedLink string) []*models.FeedItem {
+	items := make([]*models.FeedItem, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		feedItem := NewFeedItem(item, feedLink, "")
+		setFeedItemKeys(feedItem)
+		items = append(items, feedItem)
+	}
+	return items
+}
+
+// newFeedItems returns the copies of parsed that are not stored yet for the
+// user, and the stored ones the publisher revised since, carrying the id of
+// the row to update.
+func (s *service) newFeedItems(ctx context.Context, source *models.FeedSource, userId string, parsed []*models.FeedItem) ([]*models.FeedItem, []*models.FeedItem, error) {
+	cutoff, err := s.retentionCutoff(ctx, userId, source.FeedLink)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting retention cutoff: %w", err)
+	}
+
+	var items, revised []*models.FeedItem
+	batch := make(feedItemBatch)
+	for _, item := range parsed {
+		// Items past the retention age were pruned already or would be
+		// on the next run, storing them again would resurrect them as unread
+		if cutoff != nil && item.PublishedParsed != nil && item.PublishedParsed.Before(*cutoff) {
+			continue
+		}
+
+		copied := *item
+		feedItem := &copied
+		feedItem.UserId = userId
+		if !batch.add(feedItem) {
+			continue
+		}
+
+		if cutoff != nil && feedItem.PublishedParsed == nil {
+			pruned, err := isPrunedFeedItem(ctx, s.db, feedItem)
+			if err != nil {
+				return nil, nil, fmt.Errorf("checking if feed item was pruned: %w", err)
+			}
+			if pruned {
+				continue
+			}
+		}
+
+		stored, err := findStoredFeedItem(ctx, s.db, feedItem)
+		if err != nil {
+			return nil, nil, fmt.Errorf("looking up stored feed item: %w", err)
+		}
+		if stored != nil {
+			if stored.isRevisedBy(feedItem) {
+				feedItem.Id = stored.id
+				revised = append(revised, feedItem)
+			}
+			continue
+		}
+
+		duplicate, err := isDuplicateFeedItem(ctx, s.db, feedItem)
+		if err != nil {
+			return nil, nil, fmt.Errorf("checking if feed item exists: %w", err)
+		}
+		if duplicate {
+			continue
+		}
+
+		items = append(items, feedItem)
+	}
+
+	return items, revised, nil
+}
+
+func insertFeedItem(ctx context.Context, tx *sql.Tx, item *models.FeedItem) error {
+	query := `
+        INSERT INTO feeds_items (
+            feed_link, user_id, title, description, content, link, author, authors, categories, enclosures, label,
+            image_url, image_title, published, published_parsed, updated, updated_parsed, guid, normalized_link,
+            content_hash, read, starred, created_at, updated_at
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := tx.ExecContext(ctx, query,
+		item.FeedLink,
+		item.UserId,
+		item.Title,
+		item.Description,
+		item.Content,
+		item.Link,
+		item.Author,
+		jsonColumn(item.Authors),
+		jsonColumn(item.Categories),
+		jsonColumn(item.Enclosures),
+		item.Label,
+		item.ImageUrl,
+		item.ImageTitle,
+		item.Published,
+		item.PublishedParsed,
+		item.Updated,
+		item.UpdatedParsed,
+		item.GUID,
+		item.NormalizedLink,
+		item.ContentHash,
+		item.Read,
+		item.Starred,
+		item.CreatedAt,
+		item.UpdatedAt,
+	)
+
+	return err
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
 }
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"synthesis/internal/models"
+)
+
+// Query parameters that only track where a click came from
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"ref_src": true,
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// setFeedItemKeys fills the keys used to recognize an item that is already
+// stored. They must be computed from the content published by the feed,
+// before full-article extraction replaces it.
+func setFeedItemKeys(item *models.FeedItem) {
+	if item.GUID != nil && *item.GUID == "" {
+		item.GUID = nil
+	}
+	if item.NormalizedLink == nil && item.Link != nil {
+		if link := normalizeLink(*item.Link); link != "" {
+			item.NormalizedLink = &link
+		}
+	}
+	if item.ContentHash == nil {
+		if hash := contentHash(item); hash != "" {
+			item.ContentHash = &hash
+		}
+	}
+}
+
+// isDuplicateFeedItem reports whether the user already has the item. Within
+// its own feed an item with a GUID is only matched on it, since feeds such as
+// podcasts often point every item to the same page. Items without a GUID, or
+// coming from other feeds, are matched on their normalized link or content
+// hash, which collapses stories syndicated across several subscriptions.
+func isDuplicateFeedItem(ctx context.Context, q queryRower, item *models.FeedItem) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM feeds_items
+			WHERE user_id = ? AND (
+				(feed_link = ? AND guid = ?)
+				OR ((? IS NULL OR feed_link != ?) AND (normalized_link = ? OR content_hash = ?))
+			)
+		)`
+
+	var exists bool
+	err := q.QueryRowContext(ctx, query,
+		item.UserId,
+		item.FeedLink, item.GUID,
+		item.GUID, item.FeedLink, item.NormalizedLink, item.ContentHash,
+	).Scan(&exists)
+
+	return exists, err
+}
+
+// feedItemBatch deduplicates the items of a single fetch, following the same
+// rules as isDuplicateFeedItem for items of one feed.
+type feedItemBatch map[string]bool
+
+func (b feedItemBatch) add(item *models.FeedItem) bool {
+	var keys []string
+	if item.GUID != nil {
+		keys = append(keys, "guid:"+*item.GUID)
+	} else {
+		if item.NormalizedLink != nil {
+			keys = append(keys, "link:"+*item.NormalizedLink)
+		}
+		if item.ContentHash != nil {
+			keys = append(keys, "hash:"+*item.ContentHash)
+		}
+	}
+
+	for _, key := range keys {
+		if b[key] {
+			return false
+		}
+	}
+	for _, key := range keys {
+		b[key] = true
+	}
+	return true
+}
+
+// normalizeLink reduces a link to the parts that identify a story. The scheme,
+// a leading "www.", default ports, fragments, trailing slashes and tracking
+// parameters are dropped, and the remaining parameters are sorted.
+func normalizeLink(link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return ""
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return strings.ToLower(link)
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	query := u.Query()
+	for param := range query {
+		if strings.HasPrefix(strings.ToLower(param), "utm_") || trackingParams[strings.ToLower(param)] {
+			query.Del(param)
+		}
+	}
+
+	normalized := host + strings.TrimRight(u.EscapedPath(), "/")
+	if encoded := query.Encode(); encoded != "" {
+		normalized += "?" + encoded
+	}
+
+	return normalized
+}
+
+// contentHash hashes the title and text of an item, ignoring markup and
+// whitespace. Items without text get no hash, a title alone is too generic to
+// identify a story.
+func contentHash(item *models.FeedItem) string {
+	body := ""
+	if item.Content != nil && *item.Content != "" {
+		body = *item.Content
+	} else if item.Description != nil {
+		body = *item.Description
+	}
+
+	body = normalizeText(body)
+	if body == "" {
+		return ""
+	}
+
+	title := ""
+	if item.Title != nil {
+		title = normalizeText(*item.Title)
+	}
+
+	sum := sha256.Sum256([]byte(title + "\n" + body))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeText(s string) string {
+	s = html.UnescapeString(htmlTagPattern.ReplaceAllString(s, " "))
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// backfillFeedItemKeys computes the deduplication keys of the items stored
+// before they existed.
+func backfillFeedItemKeys(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "SELECT id, title, description, content, link FROM feeds_items")
+	if err != nil {
+		return err
+	}
+
+	var items []*models.FeedItem
+	for rows.Next() {
+		item := &models.FeedItem{}
+		if err := rows.Scan(&item.Id, &item.Title, &item.Description, &item.Content, &item.Link); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		setFeedItemKeys(item)
+		_, err := tx.ExecContext(ctx, "UPDATE feeds_items SET normalized_link = ?, content_hash = ? WHERE id = ?",
+			item.NormalizedLink, item.ContentHash, item.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
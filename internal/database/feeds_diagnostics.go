@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"synthesis/internal/models"
+	fetcher "synthesis/internal/services/feed-fetcher"
+	"time"
+)
+
+// Number of new items previewed in a diagnostics report
+const diagnosticsPreviewLimit = 20
+
+// DiagnoseFeed fetches one of the user's feeds and reports how the fetch
+// went and what a refresh would store for them. Nothing is written: the
+// source keeps its validators and failure count, and no item is stored.
+func (s *service) DiagnoseFeed(ctx context.Context, userId string, feedLink string) (*models.FeedDiagnostics, error) {
+	query := `
+		SELECT ` + feedSourceColumns + `, f.user_id, f.update_frequency, f.extract_content,
+			COALESCE(f.custom_title, f.title, '')
+		FROM feeds_sources fs
+		JOIN feeds f ON f.feed_link = fs.feed_link AND f.user_id = ?
+		WHERE fs.feed_link = ?`
+
+	var user, frequency, title string
+	var extractContent bool
+	source, err := scanFeedSource(s.db.QueryRowContext(ctx, query, userId, feedLink), &user, &frequency, &extractContent, &title)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("feed not found: %s", feedLink)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed source: %w", err)
+	}
+	source.UserId, source.UpdateFrequency, source.ExtractContent = user, frequency, extractContent
+
+	report := &models.FeedDiagnostics{
+		FeedLink:  feedLink,
+		Source:    source,
+		CheckedAt: time.Now(),
+	}
+
+	diagnosis, err := fetcher.Diagnose(ctx, feedLink)
+	if err != nil {
+		report.FetchError = err.Error()
+		return report, nil
+	}
+
+	report.DurationMs = diagnosis.Duration.Milliseconds()
+	report.Status = diagnosis.Status
+	report.FinalURL = diagnosis.FinalURL
+	report.ContentType = diagnosis.ContentType
+	report.ContentLength = diagnosis.ContentLength
+	report.FeedType = diagnosis.FeedType
+	report.Hub = diagnosis.Hub
+	report.FetchError = diagnosis.FetchError
+	report.ParseError = diagnosis.ParseError
+	report.Redirects = make([]models.FeedRedirect, 0, len(diagnosis.Redirects))
+	for _, redirect := range diagnosis.Redirects {
+		report.Redirects = append(report.Redirects, models.FeedRedirect(redirect))
+	}
+
+	feed := diagnosis.Feed
+	if feed == nil {
+		return report, nil
+	}
+	report.FeedVersion = feed.FeedVersion
+	report.FeedTitle = feed.Title
+	report.ItemCount = len(feed.Items)
+
+	// Rules match the title the user sees, as they do on refresh
+	delta, err := s.diagnoseFeedItems(ctx, source, userId, title, diagnosis)
+	if err != nil {
+		return nil, err
+	}
+	report.Delta = delta
+
+	return report, nil
+}
+
+// diagnoseFeedItems runs the fetched items through the same deduplication,
+// revision and rule checks as a refresh, without storing them.
+func (s *service) diagnoseFeedItems(ctx context.Context, source *models.FeedSource, userId string, feedTitle string, diagnosis *fetcher.Diagnosis) (*models.FeedItemsDelta, error) {
+	delta := &models.FeedItemsDelta{NewItems: make([]models.FeedItemPreview, 0)}
+
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM feeds_items WHERE feed_link = ? AND user_id = ?", source.FeedLink, userId).Scan(&delta.Stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count stored feed items: %w", err)
+	}
+
+	items, revised, err := s.newFeedItems(ctx, source, userId, parseFeedItems(diagnosis.Feed, source.FeedLink))
+	if err != nil {
+		return nil, err
+	}
+
+	compiled, err := s.getCompiledFeedRules(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("getting feed rules: %w", err)
+	}
+	candidates := len(items)
+	items = applyFeedRules(compiled, feedTitle, items)
+
+	delta.New = len(items)
+	delta.Revised = len(revised)
+	delta.Filtered = candidates - len(items)
+	delta.Known = len(diagnosis.Feed.Items) - candidates - delta.Revised
+
+	for _, item := range items[:min(len(items), diagnosticsPreviewLimit)] {
+		delta.NewItems = append(delta.NewItems, models.FeedItemPreview{
+			Title:     item.Title,
+			Link:      item.Link,
+			Published: item.PublishedParsed,
+		})
+	}
+
+	return delta, nil
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"log"
+	"sync"
+	"synthesis/internal/models"
+	scraper "synthesis/internal/services/article-scraper"
+)
+
+// Maximum number of articles scraped at the same time across all feeds
+const maxConcurrentExtractions = 4
+
+var extractionSlots = make(chan struct{}, maxConcurrentExtractions)
+
+// extractContent replaces the content of items with the article scraped from
+// their link when it is longer than what the feed published. Items sharing a
+// link, such as the copies of one story fanned out to several users, are
+// scraped once. Failures keep the feed's content, a broken article page must
+// not fail the refresh.
+func extractContent(ctx context.Context, items []*models.FeedItem) {
+	byLink := make(map[string][]*models.FeedItem)
+	for _, item := range items {
+		if item.Link == nil || *item.Link == "" {
+			continue
+		}
+		byLink[*item.Link] = append(byLink[*item.Link], item)
+	}
+
+	var wg sync.WaitGroup
+	for link, items := range byLink {
+		wg.Add(1)
+		go func(link string, items []*models.FeedItem) {
+			defer wg.Done()
+
+			select {
+			case extractionSlots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-extractionSlots }()
+
+			article, err := scraper.GetArticleContext(ctx, link)
+			if err != nil {
+				log.Printf("Error extracting article %s: %v", link, err)
+				return
+			}
+
+			for _, item := range items {
+				if article.Content != nil && (item.Content == nil || len(*article.Content) > len(*item.Content)) {
+					item.Content = article.Content
+				}
+				if item.ImageUrl == nil && article.Image != nil {
+					item.ImageUrl = article.Image
+				}
+			}
+		}(link, items)
+	}
+	wg.Wait()
+}
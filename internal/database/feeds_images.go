@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"synthesis/internal/models"
+	scraper "synthesis/internal/services/article-scraper"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+// itemImage returns the image of an item, falling back from the one gofeed
+// reports to its media RSS extensions, image enclosures, iTunes episode art
+// and finally the first image of its content. Relative URLs resolve against
+// the item's link.
+func itemImage(item *gofeed.Item) string {
+	var candidates []string
+	// gofeed picks the first image of the description when the feed names
+	// none, which is often a tracking pixel
+	if item.Image != nil && !isSkippedHTMLImage(item.Image.URL, item.Content, item.Description) {
+		candidates = append(candidates, item.Image.URL)
+	}
+
+	candidates = append(candidates, mediaImages(item.Extensions["media"])...)
+
+	for _, enclosure := range item.Enclosures {
+		if enclosure != nil && strings.HasPrefix(strings.ToLower(enclosure.Type), "image/") {
+			candidates = append(candidates, enclosure.URL)
+		}
+	}
+
+	if item.ITunesExt != nil {
+		candidates = append(candidates, item.ITunesExt.Image)
+	}
+
+	candidates = append(candidates, firstHTMLImage(item.Content), firstHTMLImage(item.Description))
+
+	for _, candidate := range candidates {
+		if image := resolveImageURL(item.Link, candidate); image != "" {
+			return image
+		}
+	}
+	return ""
+}
+
+// mediaImages returns the image URLs of media:content and media:thumbnail
+// elements, including the ones grouped in media:group, full size images
+// first.
+func mediaImages(media map[string][]ext.Extension) []string {
+	if media == nil {
+		return nil
+	}
+
+	var contents, thumbnails []string
+	collect := func(elements map[string][]ext.Extension) {
+		for _, content := range elements["content"] {
+			medium, mimeType := content.Attrs["medium"], strings.ToLower(content.Attrs["type"])
+			if medium == "image" || strings.HasPrefix(mimeType, "image/") {
+				contents = append(contents, content.Attrs["url"])
+			}
+			// Videos usually carry their poster as a nested thumbnail
+			for _, thumbnail := range content.Children["thumbnail"] {
+				thumbnails = append(thumbnails, thumbnail.Attrs["url"])
+			}
+		}
+		for _, thumbnail := range elements["thumbnail"] {
+			thumbnails = append(thumbnails, thumbnail.Attrs["url"])
+		}
+	}
+
+	collect(media)
+	for _, group := range media["group"] {
+		collect(group.Children)
+	}
+
+	return append(contents, thumbnails...)
+}
+
+// firstHTMLImage returns the source of the first image in an HTML fragment,
+// skipping tracking pixels and inline data.
+func firstHTMLImage(html string) string {
+	var image string
+	eachHTMLImage(html, func(src string, skipped bool) bool {
+		if skipped {
+			return true
+		}
+		image = src
+		return false
+	})
+	return image
+}
+
+// isSkippedHTMLImage reports whether image appears in one of the HTML
+// fragments as an image firstHTMLImage would skip.
+func isSkippedHTMLImage(image string, fragments ...string) bool {
+	image = strings.TrimSpace(image)
+	if strings.HasPrefix(image, "data:") {
+		return true
+	}
+
+	for _, html := range fragments {
+		found := false
+		eachHTMLImage(html, func(src string, skipped bool) bool {
+			found = skipped && src == image
+			return !found
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// eachHTMLImage calls fn with the source of every image of html and whether
+// it is a tracking pixel or inline data, until fn returns false.
+func eachHTMLImage(html string, fn func(src string, skipped bool) bool) {
+	if !strings.Contains(html, "<img") {
+		return
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return
+	}
+
+	doc.Find("img").EachWithBreak(func(_ int, img *goquery.Selection) bool {
+		src := strings.TrimSpace(img.AttrOr("src", ""))
+		if src == "" {
+			return true
+		}
+		skipped := strings.HasPrefix(src, "data:") ||
+			isTinyImage(img.AttrOr("width", "")) || isTinyImage(img.AttrOr("height", ""))
+		return fn(src, skipped)
+	})
+}
+
+func isTinyImage(dimension string) bool {
+	size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(dimension), "px"))
+	return err == nil && size <= 1
+}
+
+// resolveImageURL returns image as an absolute http(s) URL, or "" when it
+// cannot be one.
+func resolveImageURL(base string, image string) string {
+	image = strings.TrimSpace(image)
+	if image == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(image)
+	if err != nil {
+		return ""
+	}
+	if !parsed.IsAbs() {
+		baseURL, err := url.Parse(base)
+		if err != nil || !baseURL.IsAbs() {
+			return ""
+		}
+		parsed = baseURL.ResolveReference(parsed)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return ""
+	}
+
+	return parsed.String()
+}
+
+// pageImagesEnabled reports whether items still without an image get the
+// og:image of their linked page, enabled through FEED_OG_IMAGES since it
+// costs a request per item.
+func pageImagesEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("FEED_OG_IMAGES"))
+	return enabled
+}
+
+// fetchPageImages sets the image of items without one to the preview image
+// of their linked page. Like extractContent, items sharing a link are looked
+// up once and failures leave the item without an image.
+func fetchPageImages(ctx context.Context, items []*models.FeedItem) {
+	byLink := make(map[string][]*models.FeedItem)
+	for _, item := range items {
+		if item.ImageUrl != nil || item.Link == nil || *item.Link == "" {
+			continue
+		}
+		byLink[*item.Link] = append(byLink[*item.Link], item)
+	}
+
+	var wg sync.WaitGroup
+	for link, items := range byLink {
+		wg.Add(1)
+		go func(link string, items []*models.FeedItem) {
+			defer wg.Done()
+
+			select {
+			case extractionSlots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-extractionSlots }()
+
+			image, err := scraper.GetPageImage(ctx, link)
+			if err != nil {
+				log.Printf("Error getting page image %s: %v", link, err)
+				return
+			}
+
+			for _, item := range items {
+				item.ImageUrl = &image
+			}
+		}(link, items)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"synthesis/internal/models"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// NewFeedItem converts a parsed item of the feed at feedLink into the user's
+// unread copy of it.
+func NewFeedItem(item *gofeed.Item, feedLink string, userId string) *models.FeedItem {
+	now := time.Now()
+	feedItem := &models.FeedItem{
+		UserId:          userId,
+		Title:           &item.Title,
+		Description:     &item.Description,
+		Content:         &item.Content,
+		FeedLink:        feedLink,
+		Link:            &item.Link,
+		Published:       &item.Published,
+		PublishedParsed: item.PublishedParsed,
+		Updated:         &item.Updated,
+		UpdatedParsed:   item.UpdatedParsed,
+		GUID:            &item.GUID,
+		Authors:         itemAuthors(item),
+		Categories:      itemCategories(item),
+		Enclosures:      itemEnclosures(item),
+		Read:            false,
+		Starred:         false,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	if author := itemAuthor(item); author != "" {
+		feedItem.Author = &author
+	}
+
+	if image := itemImage(item); image != "" {
+		feedItem.ImageUrl = &image
+		if item.Image != nil && item.Image.Title != "" && image == resolveImageURL(item.Link, item.Image.URL) {
+			feedItem.ImageTitle = &item.Image.Title
+		}
+	}
+
+	return feedItem
+}
+
+// itemAuthor joins the names of the item's authors.
+func itemAuthor(item *gofeed.Item) string {
+	var names []string
+	for _, author := range itemAuthors(item) {
+		if author.Name != "" {
+			names = append(names, author.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func itemAuthors(item *gofeed.Item) []models.FeedItemAuthor {
+	var authors []models.FeedItemAuthor
+	for _, author := range item.Authors {
+		if author == nil {
+			continue
+		}
+		name, email := strings.TrimSpace(author.Name), strings.TrimSpace(author.Email)
+		if name != "" || email != "" {
+			authors = append(authors, models.FeedItemAuthor{Name: name, Email: email})
+		}
+	}
+	return authors
+}
+
+func itemCategories(item *gofeed.Item) []string {
+	var categories []string
+	seen := make(map[string]bool)
+	for _, category := range item.Categories {
+		category = strings.TrimSpace(category)
+		if category != "" && !seen[category] {
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
+func itemEnclosures(item *gofeed.Item) []models.FeedItemEnclosure {
+	var enclosures []models.FeedItemEnclosure
+	for _, enclosure := range item.Enclosures {
+		if enclosure == nil || strings.TrimSpace(enclosure.URL) == "" {
+			continue
+		}
+		// Feeds often publish a placeholder or an empty length
+		length, err := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64)
+		if err != nil || length < 0 {
+			length = 0
+		}
+		enclosures = append(enclosures, models.FeedItemEnclosure{
+			URL:    strings.TrimSpace(enclosure.URL),
+			Type:   strings.TrimSpace(enclosure.Type),
+			Length: length,
+		})
+	}
+	return enclosures
+}
+
+// jsonColumn stores a slice as a JSON array, or NULL when it is empty.
+func jsonColumn[T any](values []T) driver.Valuer {
+	return jsonValue[T](values)
+}
+
+type jsonValue[T any] []T
+
+func (v jsonValue[T]) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	encoded, err := json.Marshal([]T(v))
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// jsonScanner reads a JSON array column written by jsonColumn into dest.
+type jsonScanner[T any] struct {
+	dest *[]T
+}
+
+func scanJSON[T any](dest *[]T) *jsonScanner[T] {
+	return &jsonScanner[T]{dest: dest}
+}
+
+func (s *jsonScanner[T]) Scan(src any) error {
+	var raw []byte
+	switch value := src.(type) {
+	case nil:
+		*s.dest = nil
+		return nil
+	case string:
+		raw = []byte(value)
+	case []byte:
+		raw = value
+	default:
+		return fmt.Errorf("unsupported JSON column type %T", src)
+	}
+
+	return json.Unmarshal(raw, s.dest)
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"synthesis/internal/models"
+	"time"
+)
+
+// sameFeedItem matches an item o of the old URL with its copy n under the new
+// one: on their GUID, or on their normalized link when neither has a GUID.
+const sameFeedItem = `(o.guid = n.guid OR (o.guid IS NULL AND n.guid IS NULL AND o.normalized_link = n.normalized_link))`
+
+// migrateFeedLink moves a source that was permanently redirected to its new
+// URL, along with every subscription, item, retention policy and rule that
+// refers to it. Users keep their read and starred state. When the new URL is
+// a source already, the old one is merged into it: items both have are kept
+// once, with the state of either, and the subscription settings and policies
+// already set on the new URL win.
+func (s *service) migrateFeedLink(ctx context.Context, oldLink string, newLink string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Sources, feeds and items reference each other by feed_link, they can
+	// only be consistent again once all of them moved
+	if _, err := tx.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON"); err != nil {
+		return fmt.Errorf("deferring foreign keys: %w", err)
+	}
+
+	// A refresh that fetched the old URL at the same time may have moved it
+	// already
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM feeds_sources WHERE feed_link = ?)", oldLink).Scan(&exists); err != nil {
+		return fmt.Errorf("checking feed source: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	var merge bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM feeds_sources WHERE feed_link = ?)", newLink).Scan(&merge); err != nil {
+		return fmt.Errorf("checking feed source: %w", err)
+	}
+
+	now := time.Now()
+
+	if !merge {
+		_, err = tx.ExecContext(ctx, "UPDATE feeds_sources SET feed_link = ?, updated_at = ? WHERE feed_link = ?", newLink, now, oldLink)
+		if err != nil {
+			return fmt.Errorf("moving feed source: %w", err)
+		}
+	}
+
+	// Items a user already has under the new URL take the state of their
+	// copy under the old one before it is dropped. As when deduplicating a
+	// fetch, items without a GUID are recognized by their normalized link.
+	_, err = tx.ExecContext(ctx, `
+		UPDATE feeds_items AS n
+		SET read = n.read OR o.read,
+			starred = n.starred OR o.starred,
+			read_at = COALESCE(n.read_at, o.read_at),
+			read_in_bulk = CASE WHEN n.read_at IS NOT NULL THEN n.read_in_bulk ELSE o.read_in_bulk END,
+			label = COALESCE(n.label, o.label),
+			article_id = COALESCE(n.article_id, o.article_id)
+		FROM feeds_items AS o
+		WHERE n.feed_link = ? AND o.feed_link = ? AND o.user_id = n.user_id AND `+sameFeedItem,
+		newLink, oldLink)
+	if err != nil {
+		return fmt.Errorf("merging feed items: %w", err)
+	}
+
+	// Without a GUID nothing collides when moving, the merged copies have to
+	// be dropped here
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM feeds_items AS o
+		WHERE o.feed_link = ? AND o.guid IS NULL AND EXISTS (
+			SELECT 1 FROM feeds_items AS n
+			WHERE n.feed_link = ? AND n.user_id = o.user_id AND `+sameFeedItem+`
+		)`,
+		oldLink, newLink)
+	if err != nil {
+		return fmt.Errorf("merging feed items: %w", err)
+	}
+
+	for _, table := range []string{"feeds_items", "feeds_items_pruned", "feeds", "feeds_retention_policies"} {
+		_, err = tx.ExecContext(ctx, "UPDATE OR IGNORE "+table+" SET feed_link = ? WHERE feed_link = ?", newLink, oldLink)
+		if err != nil {
+			return fmt.Errorf("moving %s: %w", table, err)
+		}
+		// Rows left behind collided with one already under the new URL
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE feed_link = ?", oldLink); err != nil {
+			return fmt.Errorf("merging %s: %w", table, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE feeds_rules SET feed_link = ? WHERE feed_link = ?", newLink, oldLink); err != nil {
+		return fmt.Errorf("moving feed rules: %w", err)
+	}
+
+	if merge {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM feeds_sources WHERE feed_link = ?", oldLink); err != nil {
+			return fmt.Errorf("deleting feed source: %w", err)
+		}
+	}
+
+	// The hub subscription is for the old topic, the next fetch of the new
+	// URL discovers its hub again
+	sub, err := s.getWebSubSubscription(ctx, tx, "feed_link", oldLink)
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	if sub != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE feeds_websub SET state = ?, updated_at = ? WHERE feed_link = ?", webSubUnsubscribing, now, oldLink)
+		if err != nil {
+			return fmt.Errorf("updating websub subscription: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	if sub != nil {
+		sub.State = webSubUnsubscribing
+		go s.unsubscribeWebSub(context.WithoutCancel(ctx), sub)
+	}
+
+	return nil
+}
+
+// followPermanentRedirect migrates source to the URL its feed permanently
+// moved to and points source at it. It takes the refresh locks of both URLs,
+// so it must be called while holding neither.
+func (s *service) followPermanentRedirect(ctx context.Context, source *models.FeedSource, newLink string) error {
+	unlock := refreshingSources.lockAll(source.FeedLink, newLink)
+	defer unlock()
+
+	if err := s.migrateFeedLink(ctx, source.FeedLink, newLink); err != nil {
+		return err
+	}
+	source.FeedLink = newLink
+	return nil
+}
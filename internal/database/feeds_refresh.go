@@ -0,0 +1,250 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"sync"
+	"synthesis/internal/models"
+	"time"
+)
+
+const (
+	// Maximum number of feeds fetched at the same time
+	refreshWorkers = 8
+	// Maximum number of simultaneous fetches against a single host
+	refreshWorkersPerHost = 2
+	// Upper bound for fetching and storing a single feed
+	refreshFetchTimeout = 45 * time.Second
+	// Upper bound for sources that also scrape the articles or page images
+	// of new items
+	refreshExtractTimeout = 3 * time.Minute
+)
+
+// UpdateAllFeeds refreshes every active source whose update frequency has
+// elapsed since its last fetch.
+func (s *service) UpdateAllFeeds(ctx context.Context) (*models.FeedRefreshSummary, error) {
+	sources, err := s.getDueFeedSources(ctx, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("getting due feed sources: %w", err)
+	}
+
+	return s.refreshFeedSources(ctx, sources, nil), nil
+}
+
+// GetUserRefreshSources returns the sources of the user's active
+// subscriptions, or only the one of feedLink when it is set, for
+// RefreshFeedSources to refresh right away regardless of their update
+// frequency. Failing sources still waiting out their backoff are left out
+// and only counted, refreshing by hand must not hammer a failing publisher.
+func (s *service) GetUserRefreshSources(ctx context.Context, userId string, feedLink string) ([]*models.FeedSource, int, error) {
+	sources, err := s.getUserFeedSources(ctx, userId, feedLink)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting user feed sources: %w", err)
+	}
+
+	if feedLink != "" && len(sources) == 0 {
+		exists, err := s.FeedExists(ctx, feedLink, userId)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !exists {
+			return nil, 0, fmt.Errorf("feed not found: %s", feedLink)
+		}
+		return nil, 0, fmt.Errorf("feed is inactive: %s", feedLink)
+	}
+
+	now := time.Now()
+	ready := sources[:0]
+	for _, source := range sources {
+		if !isFeedSourceBackingOff(source, now) {
+			ready = append(ready, source)
+		}
+	}
+	backingOff := len(sources) - len(ready)
+
+	if feedLink != "" && backingOff > 0 {
+		return nil, 0, fmt.Errorf("feed is backing off after failures: %s", feedLink)
+	}
+
+	return ready, backingOff, nil
+}
+
+// RefreshFeedSources refreshes sources now, calling onRefreshed as each one
+// is done. New items are fanned out to every subscriber of a source, as on a
+// scheduled refresh.
+func (s *service) RefreshFeedSources(ctx context.Context, sources []*models.FeedSource, onRefreshed func()) *models.FeedRefreshSummary {
+	return s.refreshFeedSources(ctx, sources, onRefreshed)
+}
+
+// refreshFeedSources runs updateFeed for every source through a bounded
+// worker pool, so one slow host cannot hold up the rest of the refresh.
+// onRefreshed, when set, is called after each source.
+func (s *service) refreshFeedSources(ctx context.Context, sources []*models.FeedSource, onRefreshed func()) *models.FeedRefreshSummary {
+	start := time.Now()
+	summary := &models.FeedRefreshSummary{Sources: len(sources)}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		workers = make(chan struct{}, refreshWorkers)
+		hosts   = newHostLimiter(refreshWorkersPerHost)
+	)
+
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source *models.FeedSource) {
+			defer wg.Done()
+
+			update, err := s.refreshFeedSource(ctx, source, hosts, workers)
+			if err == nil && update.movedTo != "" {
+				oldLink := source.FeedLink
+				if err := s.followPermanentRedirect(ctx, source, update.movedTo); err != nil {
+					log.Printf("Error moving feed %s to %s: %v", oldLink, update.movedTo, err)
+				} else {
+					log.Printf("Moved feed %s to %s after a permanent redirect", oldLink, update.movedTo)
+				}
+			}
+			if onRefreshed != nil {
+				defer onRefreshed()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case err != nil:
+				log.Printf("Error updating feed %s: %v", source.FeedLink, err)
+				summary.Failed++
+				summary.Errors = append(summary.Errors, models.FeedRefreshError{
+					FeedLink: source.FeedLink,
+					Error:    err.Error(),
+				})
+			case update.notModified:
+				summary.NotModified++
+			default:
+				summary.Fetched++
+				summary.NewItems += update.newItems
+				summary.RevisedItems += update.revisedItems
+			}
+		}(source)
+	}
+
+	wg.Wait()
+	summary.Duration = time.Since(start).String()
+
+	return summary
+}
+
+// refreshFeedSource fetches and stores source once it holds its refresh lock,
+// a slot for its host and one of the workers, all released when it returns.
+func (s *service) refreshFeedSource(ctx context.Context, source *models.FeedSource, hosts *hostLimiter, workers chan struct{}) (*feedUpdateResult, error) {
+	// A source refreshed by hand while the scheduled refresh is on it waits
+	// for that run, so items are never inserted twice
+	unlock := refreshingSources.lock(source.FeedLink)
+	defer unlock()
+
+	// Take the host slot first so waiting on a busy host never occupies one
+	// of the global workers.
+	release := hosts.acquire(source.FeedLink)
+	defer release()
+
+	workers <- struct{}{}
+	defer func() { <-workers }()
+
+	timeout := refreshFetchTimeout
+	if source.ExtractContent || pageImagesEnabled() {
+		timeout = refreshExtractTimeout
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return s.updateFeed(fetchCtx, source)
+}
+
+// keyedMutex serializes work per key, dropping keys nobody holds.
+type keyedMutex struct {
+	mu   sync.Mutex
+	keys map[string]*keyedMutexEntry
+}
+
+type keyedMutexEntry struct {
+	mu   sync.Mutex
+	refs int
+}
+
+var refreshingSources = &keyedMutex{keys: make(map[string]*keyedMutexEntry)}
+
+func (k *keyedMutex) lock(key string) func() {
+	k.mu.Lock()
+	entry, ok := k.keys[key]
+	if !ok {
+		entry = &keyedMutexEntry{}
+		k.keys[key] = entry
+	}
+	entry.refs++
+	k.mu.Unlock()
+
+	entry.mu.Lock()
+	return func() {
+		entry.mu.Unlock()
+
+		k.mu.Lock()
+		entry.refs--
+		if entry.refs == 0 {
+			delete(k.keys, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// lockAll locks every key, always in the same order so that two callers
+// sharing some of them never wait on each other.
+func (k *keyedMutex) lockAll(keys ...string) func() {
+	keys = slices.Compact(slices.Sorted(slices.Values(keys)))
+
+	unlocks := make([]func(), 0, len(keys))
+	for _, key := range keys {
+		unlocks = append(unlocks, k.lock(key))
+	}
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+// hostLimiter hands out a fixed number of concurrent slots per host.
+type hostLimiter struct {
+	mu    sync.Mutex
+	limit int
+	hosts map[string]chan struct{}
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{
+		limit: limit,
+		hosts: make(map[string]chan struct{}),
+	}
+}
+
+func (l *hostLimiter) acquire(feedLink string) func() {
+	host := feedLink
+	if parsed, err := url.Parse(feedLink); err == nil && parsed.Host != "" {
+		host = parsed.Host
+	}
+
+	l.mu.Lock()
+	slots, ok := l.hosts[host]
+	if !ok {
+		slots = make(chan struct{}, l.limit)
+		l.hosts[host] = slots
+	}
+	l.mu.Unlock()
+
+	slots <- struct{}{}
+	return func() { <-slots }
+}
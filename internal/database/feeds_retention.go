@@ -0,0 +1,252 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"synthesis/internal/models"
+	"time"
+)
+
+const (
+	RetentionActionDelete  = "delete"
+	RetentionActionArchive = "archive"
+
+	// How long the keys of deleted undated items are remembered, longer
+	// than feeds usually keep an item
+	prunedItemsRetention = 365 * 24 * time.Hour
+)
+
+// A policy with an empty feed link is the user's default, feed policies
+// override it for their own feed.
+func (s *service) GetRetentionPolicies(ctx context.Context, userId string) ([]*models.RetentionPolicy, error) {
+	query := `
+		SELECT user_id, feed_link, max_age_days, action, created_at, updated_at
+		FROM feeds_retention_policies
+		WHERE user_id = ?
+		ORDER BY feed_link`
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := make([]*models.RetentionPolicy, 0)
+	for rows.Next() {
+		policy := &models.RetentionPolicy{}
+		err := rows.Scan(&policy.UserId, &policy.FeedLink, &policy.MaxAgeDays, &policy.Action, &policy.CreatedAt, &policy.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan retention policy: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
+
+func (s *service) UpsertRetentionPolicy(ctx context.Context, policy *models.RetentionPolicy) error {
+	if policy.MaxAgeDays <= 0 {
+		return fmt.Errorf("maxAgeDays must be positive")
+	}
+	if policy.Action != RetentionActionDelete && policy.Action != RetentionActionArchive {
+		return fmt.Errorf("invalid retention action: %s", policy.Action)
+	}
+
+	query := `
+		INSERT INTO feeds_retention_policies (user_id, feed_link, max_age_days, action, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, feed_link) DO UPDATE SET
+			max_age_days = excluded.max_age_days,
+			action = excluded.action,
+			updated_at = excluded.updated_at
+		RETURNING created_at, updated_at`
+
+	now := time.Now()
+	err := s.db.QueryRowContext(ctx, query, policy.UserId, policy.FeedLink, policy.MaxAgeDays, policy.Action, now, now).
+		Scan(&policy.CreatedAt, &policy.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save retention policy: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) DeleteRetentionPolicy(ctx context.Context, userId string, feedLink string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM feeds_retention_policies WHERE user_id = ? AND feed_link = ?", userId, feedLink)
+	if err != nil {
+		return fmt.Errorf("failed to delete retention policy: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("retention policy not found")
+	}
+
+	return nil
+}
+
+// PruneFeedItems applies every retention policy. Only read, non-starred items
+// older than the policy's age are deleted or archived.
+func (s *service) PruneFeedItems(ctx context.Context) (*models.RetentionSummary, error) {
+	policies, err := s.getAllRetentionPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.RetentionSummary{}
+	now := time.Now()
+
+	for _, policy := range policies {
+		cutoff := now.AddDate(0, 0, -policy.MaxAgeDays)
+
+		conditions := `
+			user_id = ? AND read = TRUE AND starred = FALSE
+			AND julianday(COALESCE(published_parsed, created_at)) < julianday(?)`
+		args := []any{policy.UserId, sqliteTime(cutoff)}
+
+		if policy.FeedLink != "" {
+			conditions += " AND feed_link = ?"
+			args = append(args, policy.FeedLink)
+		} else {
+			// The default only covers feeds without a policy of their own
+			conditions += " AND feed_link NOT IN (SELECT feed_link FROM feeds_retention_policies WHERE user_id = ? AND feed_link != '')"
+			args = append(args, policy.UserId)
+		}
+
+		var rows int64
+		if policy.Action == RetentionActionArchive {
+			rows, err = s.archiveFeedItems(ctx, conditions, args)
+		} else {
+			rows, err = s.deleteFeedItems(ctx, conditions, args, now)
+		}
+		if err != nil {
+			return summary, fmt.Errorf("pruning feed items of %s: %w", policy.UserId, err)
+		}
+
+		if policy.Action == RetentionActionArchive {
+			summary.Archived += rows
+		} else {
+			summary.Deleted += rows
+		}
+	}
+
+	_, err = s.db.ExecContext(ctx, "DELETE FROM feeds_items_pruned WHERE julianday(pruned_at) < julianday(?)", sqliteTime(now.Add(-prunedItemsRetention)))
+	if err != nil {
+		return summary, fmt.Errorf("forgetting pruned feed items: %w", err)
+	}
+
+	return summary, nil
+}
+
+func (s *service) archiveFeedItems(ctx context.Context, conditions string, args []any) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "UPDATE feeds_items SET archived = TRUE WHERE archived = FALSE AND "+conditions, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// deleteFeedItems deletes the items matching conditions. Undated items are
+// pruned by the time they were stored, so the retention cutoff of refreshes
+// cannot tell them apart from new ones: their keys are kept instead.
+func (s *service) deleteFeedItems(ctx context.Context, conditions string, args []any, now time.Time) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO feeds_items_pruned (user_id, feed_link, guid, normalized_link, content_hash, pruned_at)
+		SELECT user_id, feed_link, guid, normalized_link, content_hash, ?
+		FROM feeds_items
+		WHERE published_parsed IS NULL AND `+conditions,
+		append([]any{now}, args...)...)
+	if err != nil {
+		return 0, fmt.Errorf("remembering pruned feed items: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM feeds_items WHERE "+conditions, args...)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+	return rows, nil
+}
+
+// isPrunedFeedItem reports whether retention deleted the undated item
+// already, matching it as isDuplicateFeedItem does within its own feed.
+func isPrunedFeedItem(ctx context.Context, q queryRower, item *models.FeedItem) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM feeds_items_pruned
+			WHERE user_id = ? AND feed_link = ? AND (
+				guid = ?
+				OR (? IS NULL AND (normalized_link = ? OR content_hash = ?))
+			)
+		)`
+
+	var pruned bool
+	err := q.QueryRowContext(ctx, query,
+		item.UserId, item.FeedLink,
+		item.GUID,
+		item.GUID, item.NormalizedLink, item.ContentHash,
+	).Scan(&pruned)
+
+	return pruned, err
+}
+
+// retentionCutoff returns the publication date before which items of the feed
+// are pruned, or nil when no policy applies. Refreshes use it, along with
+// isPrunedFeedItem for undated items, to avoid inserting again items that
+// were already pruned.
+func (s *service) retentionCutoff(ctx context.Context, userId string, feedLink string) (*time.Time, error) {
+	query := `
+		SELECT max_age_days
+		FROM feeds_retention_policies
+		WHERE user_id = ? AND feed_link IN (?, '')
+		ORDER BY feed_link DESC
+		LIMIT 1`
+
+	var maxAgeDays int
+	err := s.db.QueryRowContext(ctx, query, userId, feedLink).Scan(&maxAgeDays)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -maxAgeDays)
+	return &cutoff, nil
+}
+
+func (s *service) getAllRetentionPolicies(ctx context.Context) ([]*models.RetentionPolicy, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT user_id, feed_link, max_age_days, action FROM feeds_retention_policies")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []*models.RetentionPolicy
+	for rows.Next() {
+		policy := &models.RetentionPolicy{}
+		if err := rows.Scan(&policy.UserId, &policy.FeedLink, &policy.MaxAgeDays, &policy.Action); err != nil {
+			return nil, fmt.Errorf("failed to scan retention policy: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
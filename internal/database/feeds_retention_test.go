@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"synthesis/internal/models"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T) *service {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.synthesis.db")+"?_busy_timeout=5000&_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s := &service{db: db}
+	if err := s.initTables(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestPrunedUndatedFeedItemIsNotStoredAgain(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+
+	publisher := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>Undated</title>
+			<item><title>Evergreen</title><link>https://example.com/evergreen</link></item>
+		</channel></rss>`)
+	}))
+	defer publisher.Close()
+
+	now := time.Now()
+	link := publisher.URL
+	source := &models.FeedSource{
+		FeedLink:        publisher.URL + "/feed",
+		Link:            &link,
+		UserId:          "user",
+		UpdateFrequency: DefaultUpdateFrequency,
+		LastFetch:       now,
+		Active:          true,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	feed := &models.Feed{
+		FeedLink:  source.FeedLink,
+		Link:      &link,
+		UserId:    "user",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.CreateFeed(ctx, source, feed, nil); err != nil {
+		t.Fatalf("creating feed: %v", err)
+	}
+
+	countItems := func() int {
+		t.Helper()
+		var count int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM feeds_items WHERE user_id = 'user'").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	if _, err := s.updateFeed(ctx, source); err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	if count := countItems(); count != 1 {
+		t.Fatalf("stored %d items after the first refresh, want 1", count)
+	}
+
+	_, err := s.db.Exec("UPDATE feeds_items SET read = TRUE, created_at = ?", now.AddDate(0, 0, -60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := &models.RetentionPolicy{UserId: "user", MaxAgeDays: 30, Action: RetentionActionDelete}
+	if err := s.UpsertRetentionPolicy(ctx, policy); err != nil {
+		t.Fatalf("saving retention policy: %v", err)
+	}
+
+	summary, err := s.PruneFeedItems(ctx)
+	if err != nil {
+		t.Fatalf("pruning: %v", err)
+	}
+	if summary.Deleted != 1 {
+		t.Fatalf("deleted %d items, want 1", summary.Deleted)
+	}
+
+	update, err := s.updateFeed(ctx, source)
+	if err != nil {
+		t.Fatalf("refresh after pruning: %v", err)
+	}
+	if update.newItems != 0 {
+		t.Errorf("refresh after pruning stored %d new items, want 0", update.newItems)
+	}
+	if count := countItems(); count != 0 {
+		t.Errorf("%d items after refreshing, want the pruned item to stay deleted", count)
+	}
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"synthesis/internal/models"
+	"time"
+)
+
+// storedFeedItem holds what is needed to tell whether a stored item was
+// revised upstream.
+type storedFeedItem struct {
+	id            int64
+	updatedParsed *time.Time
+	contentHash   *string
+}
+
+// findStoredFeedItem returns the user's copy of item from the same feed,
+// matched on its GUID or, for items without one, on its normalized link. It
+// returns nil when the item is not stored.
+func findStoredFeedItem(ctx context.Context, q queryRower, item *models.FeedItem) (*storedFeedItem, error) {
+	query := `
+		SELECT id, updated_parsed, content_hash
+		FROM feeds_items
+		WHERE user_id = ? AND feed_link = ? AND guid = ?`
+	args := []any{item.UserId, item.FeedLink, item.GUID}
+
+	if item.GUID == nil {
+		if item.NormalizedLink == nil {
+			return nil, nil
+		}
+		query = `
+			SELECT id, updated_parsed, content_hash
+			FROM feeds_items
+			WHERE user_id = ? AND feed_link = ? AND guid IS NULL AND normalized_link = ?
+			ORDER BY id DESC
+			LIMIT 1`
+		args = []any{item.UserId, item.FeedLink, item.NormalizedLink}
+	}
+
+	stored := &storedFeedItem{}
+	err := q.QueryRowContext(ctx, query, args...).Scan(&stored.id, &stored.updatedParsed, &stored.contentHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// isRevisedBy reports whether item is a newer version of the stored one,
+// either because the feed bumped its updated date or because its title or
+// text changed.
+func (stored *storedFeedItem) isRevisedBy(item *models.FeedItem) bool {
+	if item.UpdatedParsed != nil && (stored.updatedParsed == nil || item.UpdatedParsed.After(*stored.updatedParsed)) {
+		return true
+	}
+
+	if item.ContentHash != nil && (stored.contentHash == nil || *stored.contentHash != *item.ContentHash) {
+		return true
+	}
+
+	return false
+}
+
+// updateRevisedFeedItem stores the new version of a revised item and flags it,
+// leaving the read, starred and archived state untouched.
+func updateRevisedFeedItem(ctx context.Context, tx *sql.Tx, item *models.FeedItem) error {
+	query := `
+		UPDATE feeds_items
+		SET title = ?, description = ?, content = ?, link = ?, author = ?, authors = ?, categories = ?, enclosures = ?,
+			image_url = COALESCE(?, image_url), image_title = COALESCE(?, image_title),
+			updated = ?, updated_parsed = ?, normalized_link = ?, content_hash = ?,
+			revised = TRUE, revised_at = ?, updated_at = ?
+		WHERE id = ?`
+
+	now := time.Now()
+	_, err := tx.ExecContext(ctx, query,
+		item.Title,
+		item.Description,
+		item.Content,
+		item.Link,
+		item.Author,
+		jsonColumn(item.Authors),
+		jsonColumn(item.Categories),
+		jsonColumn(item.Enclosures),
+		item.ImageUrl,
+		item.ImageTitle,
+		item.Updated,
+		item.UpdatedParsed,
+		item.NormalizedLink,
+		item.ContentHash,
+		now,
+		now,
+		item.Id,
+	)
+
+	return err
+}
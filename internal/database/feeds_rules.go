@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"synthesis/internal/models"
+	"synthesis/internal/services/rules"
+	"time"
+)
+
+const feedRuleColumns = `id, user_id, name, feed_link, field, match_type, pattern, action, label, enabled, created_at, updated_at`
+
+func scanFeedRule(row rowScanner) (*models.FeedRule, error) {
+	rule := &models.FeedRule{}
+	err := row.Scan(
+		&rule.Id, &rule.UserId, &rule.Name, &rule.FeedLink, &rule.Field, &rule.MatchType, &rule.Pattern,
+		&rule.Action, &rule.Label, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// GetFeedRules returns the user's rules in the order they are applied.
+func (s *service) GetFeedRules(ctx context.Context, userId string) ([]*models.FeedRule, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+feedRuleColumns+` FROM feeds_rules WHERE user_id = ? ORDER BY id`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feed rules: %w", err)
+	}
+	defer rows.Close()
+
+	feedRules := make([]*models.FeedRule, 0)
+	for rows.Next() {
+		rule, err := scanFeedRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan feed rule: %w", err)
+		}
+		feedRules = append(feedRules, rule)
+	}
+
+	return feedRules, rows.Err()
+}
+
+func (s *service) CreateFeedRule(ctx context.Context, rule *models.FeedRule) (*models.FeedRule, error) {
+	if err := rules.Validate(rule); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO feeds_rules (user_id, name, feed_link, field, match_type, pattern, action, label, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	result, err := s.db.ExecContext(ctx, query,
+		rule.UserId, rule.Name, rule.FeedLink, rule.Field, rule.MatchType, rule.Pattern,
+		rule.Action, rule.Label, rule.Enabled, now, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create feed rule: %w", err)
+	}
+
+	rule.Id, err = result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed rule id: %w", err)
+	}
+
+	return rule, nil
+}
+
+func (s *service) UpdateFeedRule(ctx context.Context, rule *models.FeedRule) error {
+	if err := rules.Validate(rule); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE feeds_rules
+		SET name = ?, feed_link = ?, field = ?, match_type = ?, pattern = ?, action = ?, label = ?, enabled = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?`
+
+	rule.UpdatedAt = time.Now()
+
+	result, err := s.db.ExecContext(ctx, query,
+		rule.Name, rule.FeedLink, rule.Field, rule.MatchType, rule.Pattern, rule.Action, rule.Label, rule.Enabled,
+		rule.UpdatedAt, rule.Id, rule.UserId,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update feed rule: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("feed rule not found: %v", rule.Id)
+	}
+
+	return nil
+}
+
+func (s *service) DeleteFeedRule(ctx context.Context, id int64, userId string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM feeds_rules WHERE id = ? AND user_id = ?", id, userId)
+	if err != nil {
+		return fmt.Errorf("failed to delete feed rule: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("feed rule not found: %v", id)
+	}
+
+	return nil
+}
+
+// DryRunFeedRule matches rule against the user's most recent items without
+// changing them.
+func (s *service) DryRunFeedRule(ctx context.Context, userId string, rule *models.FeedRule, limit int) ([]*models.FeedRuleMatch, error) {
+	compiled, err := rules.Compile(rule)
+	if err != nil {
+		return nil, err
+	}
+	// Disabled rules can be tried out before turning them on
+	compiled.Enabled = true
+
+	query := fmt.Sprintf(`
+		SELECT fi.id, fi.feed_link, fi.title, fi.description, fi.content, fi.link, fi.author, fi.published_parsed,
+			COALESCE(f.custom_title, f.title)
+		FROM feeds_items fi
+		JOIN feeds f ON fi.feed_link = f.feed_link AND fi.user_id = f.user_id
+		WHERE fi.user_id = ?
+		ORDER BY %s DESC, fi.id DESC
+		LIMIT ?`, feedItemSortKey)
+
+	rows, err := s.db.QueryContext(ctx, query, userId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feed items: %w", err)
+	}
+	defer rows.Close()
+
+	matches := make([]*models.FeedRuleMatch, 0)
+	for rows.Next() {
+		var (
+			match                       = &models.FeedRuleMatch{Action: rule.Action}
+			description, content, title *string
+		)
+		err := rows.Scan(&match.Id, &match.FeedLink, &match.Title, &description, &content, &match.Link, &match.Author,
+			&match.Published, &title)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan feed item: %w", err)
+		}
+
+		item := rules.Item{
+			FeedLink:    match.FeedLink,
+			FeedTitle:   deref(title),
+			Title:       deref(match.Title),
+			Description: deref(description),
+			Content:     deref(content),
+			Author:      deref(match.Author),
+		}
+		if compiled.Matches(item) {
+			matches = append(matches, match)
+		}
+	}
+
+	return matches, rows.Err()
+}
+
+// getCompiledFeedRules returns the user's enabled rules ready for matching.
+// Rules are validated when saved, one failing to compile is skipped.
+func (s *service) getCompiledFeedRules(ctx context.Context, userId string) ([]*rules.Rule, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+feedRuleColumns+` FROM feeds_rules WHERE user_id = ? AND enabled = TRUE ORDER BY id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var compiled []*rules.Rule
+	for rows.Next() {
+		rule, err := scanFeedRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		if c, err := rules.Compile(rule); err == nil {
+			compiled = append(compiled, c)
+		}
+	}
+
+	return compiled, rows.Err()
+}
+
+// applyFeedRules runs the rules over new items, setting their read, starred
+// and label state, and returns the items that were not dropped.
+func applyFeedRules(compiled []*rules.Rule, feedTitle string, items []*models.FeedItem) []*models.FeedItem {
+	if len(compiled) == 0 {
+		return items
+	}
+
+	kept := items[:0]
+	for _, item := range items {
+		outcome := rules.Evaluate(compiled, rules.Item{
+			FeedLink:    item.FeedLink,
+			FeedTitle:   feedTitle,
+			Title:       deref(item.Title),
+			Description: deref(item.Description),
+			Content:     deref(item.Content),
+			Author:      deref(item.Author),
+		})
+		if outcome.Drop {
+			continue
+		}
+
+		item.Read = item.Read || outcome.Read
+		item.Starred = item.Starred || outcome.Star
+		if outcome.Label != "" {
+			item.Label = &outcome.Label
+		}
+		kept = append(kept, item)
+	}
+
+	return kept
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"synthesis/internal/models"
+	"time"
+)
+
+const sharedFeedColumns = `token, user_id, source, label, title, created_at`
+
+func scanSharedFeed(row rowScanner) (*models.SharedFeed, error) {
+	feed := &models.SharedFeed{}
+	if err := row.Scan(&feed.Token, &feed.UserId, &feed.Source, &feed.Label, &feed.Title, &feed.CreatedAt); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+func (s *service) GetSharedFeeds(ctx context.Context, userId string) ([]*models.SharedFeed, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sharedFeedColumns+` FROM shared_feeds WHERE user_id = ? ORDER BY created_at`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shared feeds: %w", err)
+	}
+	defer rows.Close()
+
+	feeds := make([]*models.SharedFeed, 0)
+	for rows.Next() {
+		feed, err := scanSharedFeed(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shared feed: %w", err)
+		}
+		feeds = append(feeds, feed)
+	}
+
+	return feeds, rows.Err()
+}
+
+// GetSharedFeed returns the shared feed with the given token, whoever owns it.
+func (s *service) GetSharedFeed(ctx context.Context, token string) (*models.SharedFeed, error) {
+	feed, err := scanSharedFeed(s.db.QueryRowContext(ctx, `SELECT `+sharedFeedColumns+` FROM shared_feeds WHERE token = ?`, token))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("shared feed not found: %s", token)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared feed: %w", err)
+	}
+
+	return feed, nil
+}
+
+func (s *service) CreateSharedFeed(ctx context.Context, feed *models.SharedFeed) error {
+	feed.CreatedAt = time.Now()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO shared_feeds (token, user_id, source, label, title, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		feed.Token, feed.UserId, feed.Source, feed.Label, feed.Title, feed.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create shared feed: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteSharedFeed revokes a shared feed, its URL stops working right away.
+func (s *service) DeleteSharedFeed(ctx context.Context, token string, userId string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM shared_feeds WHERE token = ? AND user_id = ?", token, userId)
+	if err != nil {
+		return fmt.Errorf("failed to delete shared feed: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("shared feed not found: %s", token)
+	}
+
+	return nil
+}
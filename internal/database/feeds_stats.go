@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"synthesis/internal/models"
+	"time"
+)
+
+// Number of feeds listed in the most read ranking
+const mostReadFeedsLimit = 10
+
+// GetFeedStats returns the user's item counts per feed, per label and
+// overall, and what they read over the last days. An item counts toward both
+// the label of its feed and its own label, matching the label filter of
+// GetFeedItems. Reads only count items read one by one, not those caught by
+// a scoped or mark-all bulk mark.
+func (s *service) GetFeedStats(ctx context.Context, userId string, days int) (*models.FeedStats, error) {
+	stats := &models.FeedStats{
+		Feeds:       make([]models.FeedCounts, 0),
+		Labels:      make([]models.LabelCounts, 0),
+		Days:        days,
+		ReadsPerDay: make([]models.DailyReads, 0, days),
+		MostRead:    make([]models.FeedReads, 0),
+	}
+
+	feedsQuery := `
+		SELECT f.feed_link, COALESCE(f.custom_title, f.title),
+			COUNT(fi.id),
+			COALESCE(SUM(fi.read = FALSE), 0),
+			COALESCE(SUM(fi.starred = TRUE), 0)
+		FROM feeds f
+		LEFT JOIN feeds_items fi ON fi.feed_link = f.feed_link AND fi.user_id = f.user_id AND fi.archived = FALSE
+		WHERE f.user_id = ?
+		GROUP BY f.feed_link
+		ORDER BY COALESCE(f.custom_title, f.title)`
+
+	rows, err := s.db.QueryContext(ctx, feedsQuery, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feed counts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var counts models.FeedCounts
+		if err := rows.Scan(&counts.FeedLink, &counts.Title, &counts.Total, &counts.Unread, &counts.Starred); err != nil {
+			return nil, fmt.Errorf("failed to scan feed counts: %w", err)
+		}
+		stats.Overall.Total += counts.Total
+		stats.Overall.Unread += counts.Unread
+		stats.Overall.Starred += counts.Starred
+		stats.Feeds = append(stats.Feeds, counts)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	labelsQuery := `
+		SELECT label, COUNT(*), SUM(read = FALSE), SUM(starred = TRUE)
+		FROM (
+			SELECT f.label AS label, fi.read, fi.starred
+			FROM feeds_items fi
+			JOIN feeds f ON fi.feed_link = f.feed_link AND fi.user_id = f.user_id
+			WHERE fi.user_id = ? AND fi.archived = FALSE AND COALESCE(f.label, '') != ''
+			UNION ALL
+			SELECT fi.label, fi.read, fi.starred
+			FROM feeds_items fi
+			JOIN feeds f ON fi.feed_link = f.feed_link AND fi.user_id = f.user_id
+			WHERE fi.user_id = ? AND fi.archived = FALSE AND COALESCE(fi.label, '') != ''
+				AND fi.label != COALESCE(f.label, '')
+		)
+		GROUP BY label
+		ORDER BY label`
+
+	labelRows, err := s.db.QueryContext(ctx, labelsQuery, userId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query label counts: %w", err)
+	}
+	defer labelRows.Close()
+
+	for labelRows.Next() {
+		var counts models.LabelCounts
+		if err := labelRows.Scan(&counts.Label, &counts.Total, &counts.Unread, &counts.Starred); err != nil {
+			return nil, fmt.Errorf("failed to scan label counts: %w", err)
+		}
+		stats.Labels = append(stats.Labels, counts)
+	}
+	if err := labelRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Days are counted in UTC, the oldest one being days-1 days before today
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(days - 1))
+
+	readsQuery := `
+		SELECT date(read_at), COUNT(*)
+		FROM feeds_items
+		WHERE user_id = ? AND read_at IS NOT NULL AND read_in_bulk = FALSE AND julianday(read_at) >= julianday(?)
+		GROUP BY date(read_at)`
+
+	readRows, err := s.db.QueryContext(ctx, readsQuery, userId, sqliteTime(since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reads per day: %w", err)
+	}
+	defer readRows.Close()
+
+	readsByDate := make(map[string]int)
+	for readRows.Next() {
+		var date string
+		var count int
+		if err := readRows.Scan(&date, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan reads per day: %w", err)
+		}
+		readsByDate[date] = count
+	}
+	if err := readRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Days without reads are listed too, so the series has no gaps
+	for day := since; !day.After(today); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		stats.ReadsPerDay = append(stats.ReadsPerDay, models.DailyReads{Date: date, Count: readsByDate[date]})
+	}
+
+	mostReadQuery := `
+		SELECT fi.feed_link, COALESCE(f.custom_title, f.title), COUNT(*) AS reads
+		FROM feeds_items fi
+		JOIN feeds f ON fi.feed_link = f.feed_link AND fi.user_id = f.user_id
+		WHERE fi.user_id = ? AND fi.read_at IS NOT NULL AND fi.read_in_bulk = FALSE AND julianday(fi.read_at) >= julianday(?)
+		GROUP BY fi.feed_link
+		ORDER BY reads DESC, fi.feed_link
+		LIMIT ?`
+
+	mostReadRows, err := s.db.QueryContext(ctx, mostReadQuery, userId, sqliteTime(since), mostReadFeedsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query most read feeds: %w", err)
+	}
+	defer mostReadRows.Close()
+
+	for mostReadRows.Next() {
+		var reads models.FeedReads
+		if err := mostReadRows.Scan(&reads.FeedLink, &reads.Title, &reads.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan most read feeds: %w", err)
+		}
+		stats.MostRead = append(stats.MostRead, reads)
+	}
+
+	return stats, mostReadRows.Err()
+}
@@ -0,0 +1,340 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"synthesis/internal/models"
+	fetcher "synthesis/internal/services/feed-fetcher"
+	"synthesis/internal/services/websub"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+const (
+	webSubPending       = "pending"
+	webSubSubscribed    = "subscribed"
+	webSubDenied        = "denied"
+	webSubFailed        = "failed"
+	webSubUnsubscribing = "unsubscribing"
+
+	// Leases are renewed this long before they expire
+	webSubRenewBefore = 24 * time.Hour
+	// Requests the hub rejected or never confirmed are retried after
+	webSubRetryAfter = time.Hour
+)
+
+const webSubColumns = `feed_link, hub, topic, callback_id, secret, state, lease_seconds, expires_at, last_error, created_at, updated_at`
+
+func scanWebSubSubscription(row rowScanner) (*models.WebSubSubscription, error) {
+	sub := &models.WebSubSubscription{}
+	err := row.Scan(
+		&sub.FeedLink, &sub.Hub, &sub.Topic, &sub.CallbackId, &sub.Secret, &sub.State, &sub.LeaseSeconds,
+		&sub.ExpiresAt, &sub.LastError, &sub.CreatedAt, &sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (s *service) getWebSubSubscription(ctx context.Context, q queryRower, column string, value string) (*models.WebSubSubscription, error) {
+	row := q.QueryRowContext(ctx, `SELECT `+webSubColumns+` FROM feeds_websub WHERE `+column+` = ?`, value)
+	sub, err := scanWebSubSubscription(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("websub subscription not found: %s", value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get websub subscription: %w", err)
+	}
+	return sub, nil
+}
+
+// GetWebSubSubscription returns the subscription hubs deliver to at the
+// callback with the given id.
+func (s *service) GetWebSubSubscription(ctx context.Context, callbackId string) (*models.WebSubSubscription, error) {
+	return s.getWebSubSubscription(ctx, s.db, "callback_id", callbackId)
+}
+
+// SubscribeWebSub subscribes the source to hub for topic, which defaults to
+// the feed link. A subscription that is current or waiting for the hub's
+// confirmation is left alone. It does nothing unless PUBLIC_BASE_URL is set.
+func (s *service) SubscribeWebSub(ctx context.Context, feedLink string, hub string, topic string) error {
+	if !websub.Enabled() {
+		return nil
+	}
+	if topic == "" {
+		topic = feedLink
+	}
+
+	existing, err := s.getWebSubSubscription(ctx, s.db, "feed_link", feedLink)
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+
+	if existing != nil && existing.Hub == hub && existing.Topic == topic {
+		now := time.Now()
+		switch {
+		case existing.State == webSubSubscribed && existing.ExpiresAt != nil && existing.ExpiresAt.After(now.Add(webSubRenewBefore)):
+			return nil
+		case existing.State == webSubPending && existing.UpdatedAt.After(now.Add(-webSubRetryAfter)):
+			return nil
+		}
+	}
+
+	return s.subscribeWebSub(ctx, feedLink, hub, topic, existing)
+}
+
+// subscribeWebSub records a pending subscription and asks the hub for it. The
+// callback and secret of an existing subscription are kept, so content the
+// hub signed before a renewal still verifies.
+func (s *service) subscribeWebSub(ctx context.Context, feedLink string, hub string, topic string, existing *models.WebSubSubscription) error {
+	var callbackId, secret string
+	if existing != nil {
+		callbackId, secret = existing.CallbackId, existing.Secret
+	} else {
+		var err error
+		if callbackId, err = websub.NewToken(); err != nil {
+			return fmt.Errorf("generating callback id: %w", err)
+		}
+		if secret, err = websub.NewToken(); err != nil {
+			return fmt.Errorf("generating secret: %w", err)
+		}
+	}
+
+	// The hub may verify the callback before answering, so the subscription
+	// is stored first
+	query := `
+		INSERT INTO feeds_websub (feed_link, hub, topic, callback_id, secret, state, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (feed_link) DO UPDATE SET
+			hub = excluded.hub, topic = excluded.topic, state = excluded.state, last_error = NULL,
+			updated_at = excluded.updated_at`
+
+	now := time.Now()
+	_, err := s.db.ExecContext(ctx, query, feedLink, hub, topic, callbackId, secret, webSubPending, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to save websub subscription: %w", err)
+	}
+
+	err = websub.Subscribe(ctx, hub, topic, websub.CallbackURL(callbackId), secret, websub.DefaultLeaseSeconds)
+	if err != nil {
+		_, errUpdate := s.db.ExecContext(context.WithoutCancel(ctx),
+			"UPDATE feeds_websub SET state = ?, last_error = ?, updated_at = ? WHERE feed_link = ? AND state = ?",
+			webSubFailed, err.Error(), time.Now(), feedLink, webSubPending)
+		if errUpdate != nil {
+			return fmt.Errorf("subscribing to hub %w, updating subscription %w", err, errUpdate)
+		}
+		return fmt.Errorf("subscribing to hub: %w", err)
+	}
+
+	return nil
+}
+
+// discoverWebSubHub subscribes a polled source to the hub it advertises when
+// it has no subscription to that hub yet, without holding up the refresh.
+// Retrying failed subscriptions is left to RenewWebSubSubscriptions.
+func (s *service) discoverWebSubHub(ctx context.Context, feedLink string, hub string, topic string) {
+	if !websub.Enabled() {
+		return
+	}
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM feeds_websub WHERE feed_link = ? AND hub = ?)", feedLink, hub).Scan(&exists)
+	if err != nil || exists {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshFetchTimeout)
+		defer cancel()
+
+		if err := s.SubscribeWebSub(ctx, feedLink, hub, topic); err != nil {
+			log.Printf("Error subscribing %s to hub %s: %v", feedLink, hub, err)
+		}
+	}()
+}
+
+// ConfirmWebSub answers the hub verifying a subscribe or unsubscribe request
+// for the callback. It fails when the topic does not match or the request is
+// not one we made, in which case the hub must not go through with it.
+func (s *service) ConfirmWebSub(ctx context.Context, callbackId string, mode string, topic string, leaseSeconds int) error {
+	sub, err := s.GetWebSubSubscription(ctx, callbackId)
+	if err != nil {
+		return err
+	}
+	if sub.Topic != topic {
+		return fmt.Errorf("websub topic mismatch: %s", topic)
+	}
+
+	switch mode {
+	case websub.ModeSubscribe:
+		if sub.State == webSubUnsubscribing {
+			return fmt.Errorf("websub subscription is being removed: %s", sub.FeedLink)
+		}
+
+		// Without a lease the hub granted the one we asked for
+		if leaseSeconds <= 0 {
+			leaseSeconds = websub.DefaultLeaseSeconds
+		}
+		expiresAt := time.Now().Add(time.Duration(leaseSeconds) * time.Second)
+
+		_, err = s.db.ExecContext(ctx,
+			"UPDATE feeds_websub SET state = ?, lease_seconds = ?, expires_at = ?, last_error = NULL, updated_at = ? WHERE callback_id = ?",
+			webSubSubscribed, leaseSeconds, expiresAt, time.Now(), callbackId)
+	case websub.ModeUnsubscribe:
+		if sub.State != webSubUnsubscribing {
+			return fmt.Errorf("websub subscription is not being removed: %s", sub.FeedLink)
+		}
+
+		_, err = s.db.ExecContext(ctx, "DELETE FROM feeds_websub WHERE callback_id = ?", callbackId)
+	default:
+		return fmt.Errorf("invalid websub mode: %s", mode)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to confirm websub subscription: %w", err)
+	}
+
+	return nil
+}
+
+// IsWebSubVerified reports whether the hub confirmed sub and its lease still
+// runs at now, so the content it delivers can be trusted. A renewal waiting
+// for the hub keeps the lease of the previous confirmation.
+func IsWebSubVerified(sub *models.WebSubSubscription, now time.Time) bool {
+	switch sub.State {
+	case webSubSubscribed, webSubPending, webSubFailed:
+		return sub.ExpiresAt != nil && sub.ExpiresAt.After(now)
+	default:
+		return false
+	}
+}
+
+// DenyWebSub records that the hub refused the subscription. Polling carries
+// on as before.
+func (s *service) DenyWebSub(ctx context.Context, callbackId string, topic string, reason string) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE feeds_websub SET state = ?, last_error = ?, updated_at = ? WHERE callback_id = ? AND topic = ?",
+		webSubDenied, nullIfEmpty(reason), time.Now(), callbackId, topic)
+	if err != nil {
+		return fmt.Errorf("failed to deny websub subscription: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("websub subscription not found: %s", callbackId)
+	}
+
+	return nil
+}
+
+// IngestWebSubContent stores a feed document pushed by the hub the same way
+// a polled one is, and returns how many new items it brought.
+func (s *service) IngestWebSubContent(ctx context.Context, feedLink string, feed *gofeed.Feed) (int, error) {
+	// Pushes and polls of one source are stored one at a time
+	unlock := refreshingSources.lock(feedLink)
+	defer unlock()
+
+	sources, err := s.queryActiveFeedSources(ctx, "AND fs.feed_link = ?", feedLink)
+	if err != nil {
+		return 0, fmt.Errorf("getting feed source: %w", err)
+	}
+	// Nobody reads the feed anymore
+	if len(sources) == 0 {
+		return 0, nil
+	}
+	source := sources[0]
+
+	timeout := refreshFetchTimeout
+	if source.ExtractContent || pageImagesEnabled() {
+		timeout = refreshExtractTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// A push says nothing about the validators of the feed document
+	result := &fetcher.Result{Feed: feed, ETag: deref(source.ETag), LastModified: deref(source.LastModified)}
+
+	update, err := s.storeFeedUpdate(ctx, source, result)
+	if err != nil {
+		return 0, err
+	}
+
+	return update.newItems, nil
+}
+
+// RenewWebSubSubscriptions renews the leases about to expire and retries the
+// subscriptions the hub rejected or never confirmed. Unsubscriptions the hub
+// never confirmed are dropped. It returns how many requests were sent.
+func (s *service) RenewWebSubSubscriptions(ctx context.Context) (int, error) {
+	if !websub.Enabled() {
+		return 0, nil
+	}
+
+	now := time.Now()
+	query := `
+		SELECT ` + webSubColumns + `
+		FROM feeds_websub
+		WHERE (state = ? AND julianday(expires_at) < julianday(?))
+			OR (state IN (?, ?, ?) AND julianday(updated_at) < julianday(?))`
+
+	rows, err := s.db.QueryContext(ctx, query,
+		webSubSubscribed, sqliteTime(now.Add(webSubRenewBefore)),
+		webSubPending, webSubFailed, webSubUnsubscribing, sqliteTime(now.Add(-webSubRetryAfter)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query websub subscriptions: %w", err)
+	}
+
+	var subs []*models.WebSubSubscription
+	for rows.Next() {
+		sub, err := scanWebSubSubscription(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan websub subscription: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	renewed := 0
+	for _, sub := range subs {
+		if sub.State == webSubUnsubscribing {
+			if _, err := s.db.ExecContext(ctx, "DELETE FROM feeds_websub WHERE feed_link = ? AND state = ?", sub.FeedLink, webSubUnsubscribing); err != nil {
+				return renewed, fmt.Errorf("failed to delete websub subscription: %w", err)
+			}
+			continue
+		}
+
+		if err := s.subscribeWebSub(ctx, sub.FeedLink, sub.Hub, sub.Topic, sub); err != nil {
+			log.Printf("Error renewing websub subscription of %s: %v", sub.FeedLink, err)
+			continue
+		}
+		renewed++
+	}
+
+	return renewed, nil
+}
+
+// unsubscribeWebSub asks the hub to stop pushing a source nobody follows
+// anymore. The subscription is dropped when the hub cannot be reached, its
+// callbacks are answered with not found from then on.
+func (s *service) unsubscribeWebSub(ctx context.Context, sub *models.WebSubSubscription) {
+	err := websub.Unsubscribe(ctx, sub.Hub, sub.Topic, websub.CallbackURL(sub.CallbackId))
+	if err == nil {
+		return
+	}
+
+	log.Printf("Error unsubscribing %s from hub %s: %v", sub.FeedLink, sub.Hub, err)
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM feeds_websub WHERE feed_link = ? AND state = ?", sub.FeedLink, webSubUnsubscribing); err != nil {
+		log.Printf("Error deleting websub subscription of %s: %v", sub.FeedLink, err)
+	}
+}
@@ -0,0 +1,315 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// migrations are applied in order on top of the tables created by
+// initTables. The number of applied migrations is tracked in PRAGMA
+// user_version, so entries must never be edited or reordered, only appended.
+var migrations = []string{
+	// Conditional fetching of feed sources
+	`ALTER TABLE feeds_sources ADD COLUMN etag TEXT;
+	ALTER TABLE feeds_sources ADD COLUMN last_modified TEXT;`,
+	// Failure tracking for backoff and deactivation of feed sources
+	`ALTER TABLE feeds_sources ADD COLUMN last_error TEXT;
+	ALTER TABLE feeds_sources ADD COLUMN last_error_at DATETIME;`,
+	// User defined feed titles that survive refreshes
+	`ALTER TABLE feeds ADD COLUMN custom_title TEXT;`,
+	// Keyset pagination of feed items
+	`CREATE INDEX IF NOT EXISTS idx_feeds_items_user_sort ON feeds_items (user_id, COALESCE(published_parsed, created_at), id);`,
+	// Retention policies for feed items
+	`CREATE TABLE IF NOT EXISTS feeds_retention_policies (
+		user_id TEXT NOT NULL,
+		feed_link TEXT NOT NULL DEFAULT '',
+		max_age_days INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		PRIMARY KEY (user_id, feed_link)
+	);
+	ALTER TABLE feeds_items ADD COLUMN archived BOOLEAN NOT NULL DEFAULT FALSE;`,
+	// Opt-in full-article extraction for feeds that only publish summaries
+	`ALTER TABLE feeds_sources ADD COLUMN extract_content BOOLEAN NOT NULL DEFAULT FALSE;`,
+	// Articles saved from feed items
+	`ALTER TABLE feeds_items ADD COLUMN article_id TEXT REFERENCES articles(id) ON DELETE SET NULL;`,
+	// Per user deduplication of feed items, guid is no longer globally unique
+	`CREATE TABLE feeds_items_new (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		title TEXT,
+		description TEXT,
+		content TEXT,
+		feed_link TEXT NOT NULL,
+		link TEXT NOT NULL,
+		image_url TEXT,
+		image_title TEXT,
+		published DATETIME,
+		published_parsed DATETIME,
+		updated DATETIME,
+		updated_parsed DATETIME,
+		guid TEXT,
+		read BOOLEAN DEFAULT FALSE,
+		starred BOOLEAN DEFAULT FALSE,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		archived BOOLEAN NOT NULL DEFAULT FALSE,
+		article_id TEXT REFERENCES articles(id) ON DELETE SET NULL,
+		normalized_link TEXT,
+		content_hash TEXT,
+		FOREIGN KEY (feed_link) REFERENCES feeds(feed_link)
+	);
+	INSERT INTO feeds_items_new (
+		id, user_id, title, description, content, feed_link, link, image_url, image_title, published,
+		published_parsed, updated, updated_parsed, guid, read, starred, created_at, updated_at, archived, article_id
+	)
+	SELECT
+		id, user_id, title, description, content, feed_link, link, image_url, image_title, published,
+		published_parsed, updated, updated_parsed, NULLIF(guid, ''), read, starred, created_at, updated_at, archived, article_id
+	FROM feeds_items;
+	DROP TABLE feeds_items;
+	ALTER TABLE feeds_items_new RENAME TO feeds_items;
+	CREATE INDEX idx_feeds_items_user_sort ON feeds_items (user_id, COALESCE(published_parsed, created_at), id);
+	CREATE UNIQUE INDEX idx_feeds_items_user_guid ON feeds_items (user_id, feed_link, guid) WHERE guid IS NOT NULL;
+	CREATE INDEX idx_feeds_items_user_link ON feeds_items (user_id, normalized_link);
+	CREATE INDEX idx_feeds_items_user_hash ON feeds_items (user_id, content_hash);`,
+	// Sources are shared by every user subscribed to the same feed, per user
+	// settings move to feeds. Sources deactivated without an error were
+	// paused by their user, the others were deactivated after failures.
+	`CREATE TABLE feeds_sources_new (
+		feed_link TEXT PRIMARY KEY,
+		link TEXT NOT NULL,
+		last_fetch DATETIME,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		failure_count INTEGER NOT NULL DEFAULT 0,
+		etag TEXT,
+		last_modified TEXT,
+		last_error TEXT,
+		last_error_at DATETIME,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	INSERT INTO feeds_sources_new (
+		feed_link, link, last_fetch, active, failure_count, etag, last_modified, last_error, last_error_at, created_at, updated_at
+	)
+	SELECT
+		feed_link, link, last_fetch, CASE WHEN last_error IS NULL THEN TRUE ELSE COALESCE(active, TRUE) END,
+		COALESCE(failure_count, 0), etag, last_modified, last_error, last_error_at, created_at, updated_at
+	FROM feeds_sources;
+	CREATE TABLE feeds_new (
+		feed_link TEXT NOT NULL,
+		link TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		title TEXT,
+		description TEXT,
+		label TEXT,
+		image_url TEXT,
+		image_title TEXT,
+		updated DATETIME,
+		updated_parsed DATETIME,
+		feed_type TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		custom_title TEXT,
+		update_frequency TEXT NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		extract_content BOOLEAN NOT NULL DEFAULT FALSE,
+		PRIMARY KEY (feed_link, user_id),
+		FOREIGN KEY (feed_link) REFERENCES feeds_sources(feed_link)
+	);
+	INSERT INTO feeds_new (
+		feed_link, link, user_id, title, description, label, image_url, image_title, updated, updated_parsed,
+		feed_type, created_at, updated_at, custom_title, update_frequency, active, extract_content
+	)
+	SELECT
+		f.feed_link, f.link, f.user_id, f.title, f.description, f.label, f.image_url, f.image_title, f.updated, f.updated_parsed,
+		f.feed_type, f.created_at, f.updated_at, f.custom_title, COALESCE(fs.update_frequency, '1h'),
+		NOT (COALESCE(fs.active, TRUE) = FALSE AND fs.last_error IS NULL), COALESCE(fs.extract_content, FALSE)
+	FROM feeds f
+	LEFT JOIN feeds_sources fs ON fs.feed_link = f.feed_link;
+	CREATE TABLE feeds_items_new (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		title TEXT,
+		description TEXT,
+		content TEXT,
+		feed_link TEXT NOT NULL,
+		link TEXT NOT NULL,
+		image_url TEXT,
+		image_title TEXT,
+		published DATETIME,
+		published_parsed DATETIME,
+		updated DATETIME,
+		updated_parsed DATETIME,
+		guid TEXT,
+		read BOOLEAN DEFAULT FALSE,
+		starred BOOLEAN DEFAULT FALSE,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		archived BOOLEAN NOT NULL DEFAULT FALSE,
+		article_id TEXT REFERENCES articles(id) ON DELETE SET NULL,
+		normalized_link TEXT,
+		content_hash TEXT,
+		FOREIGN KEY (feed_link, user_id) REFERENCES feeds(feed_link, user_id)
+	);
+	INSERT INTO feeds_items_new SELECT
+		id, user_id, title, description, content, feed_link, link, image_url, image_title, published,
+		published_parsed, updated, updated_parsed, guid, read, starred, created_at, updated_at, archived, article_id,
+		normalized_link, content_hash
+	FROM feeds_items;
+	DROP TABLE feeds_items;
+	DROP TABLE feeds;
+	DROP TABLE feeds_sources;
+	ALTER TABLE feeds_sources_new RENAME TO feeds_sources;
+	ALTER TABLE feeds_new RENAME TO feeds;
+	ALTER TABLE feeds_items_new RENAME TO feeds_items;
+	CREATE INDEX idx_feeds_user ON feeds (user_id);
+	CREATE INDEX idx_feeds_items_user_sort ON feeds_items (user_id, COALESCE(published_parsed, created_at), id);
+	CREATE UNIQUE INDEX idx_feeds_items_user_guid ON feeds_items (user_id, feed_link, guid) WHERE guid IS NOT NULL;
+	CREATE INDEX idx_feeds_items_user_link ON feeds_items (user_id, normalized_link);
+	CREATE INDEX idx_feeds_items_user_hash ON feeds_items (user_id, content_hash);`,
+	// Items edited upstream after they were stored
+	`ALTER TABLE feeds_items ADD COLUMN revised BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE feeds_items ADD COLUMN revised_at DATETIME;`,
+	// Per user filter rules applied to new feed items
+	`CREATE TABLE IF NOT EXISTS feeds_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		feed_link TEXT,
+		field TEXT NOT NULL,
+		match_type TEXT NOT NULL,
+		pattern TEXT NOT NULL,
+		action TEXT NOT NULL,
+		label TEXT,
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE INDEX idx_feeds_rules_user ON feeds_rules (user_id);
+	ALTER TABLE feeds_items ADD COLUMN author TEXT;
+	ALTER TABLE feeds_items ADD COLUMN label TEXT;`,
+	// Item authors, categories and enclosures, stored as JSON arrays
+	`ALTER TABLE feeds_items ADD COLUMN authors TEXT;
+	ALTER TABLE feeds_items ADD COLUMN categories TEXT;
+	ALTER TABLE feeds_items ADD COLUMN enclosures TEXT;`,
+	// When the user read an item, for reading statistics
+	`ALTER TABLE feeds_items ADD COLUMN read_at DATETIME;
+	CREATE INDEX idx_feeds_items_user_read_at ON feeds_items (user_id, read_at);`,
+	// WebSub push subscriptions of feed sources to their hubs
+	`CREATE TABLE IF NOT EXISTS feeds_websub (
+		feed_link TEXT PRIMARY KEY,
+		hub TEXT NOT NULL,
+		topic TEXT NOT NULL,
+		callback_id TEXT NOT NULL UNIQUE,
+		secret TEXT NOT NULL,
+		state TEXT NOT NULL,
+		lease_seconds INTEGER,
+		expires_at DATETIME,
+		last_error TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`,
+	// Tokenized public feeds users publish from their items, articles or notes
+	`CREATE TABLE IF NOT EXISTS shared_feeds (
+		token TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		source TEXT NOT NULL,
+		label TEXT,
+		title TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX idx_shared_feeds_user ON shared_feeds (user_id);`,
+	// Keys of undated feed items deleted by retention, still recognized when
+	// their feed keeps publishing them
+	`CREATE TABLE IF NOT EXISTS feeds_items_pruned (
+		user_id TEXT NOT NULL,
+		feed_link TEXT NOT NULL,
+		guid TEXT,
+		normalized_link TEXT,
+		content_hash TEXT,
+		pruned_at DATETIME NOT NULL
+	);
+	CREATE INDEX idx_feeds_items_pruned_user_feed ON feeds_items_pruned (user_id, feed_link);`,
+	// Hubs confirming without a lease granted the default one we asked for
+	`UPDATE feeds_websub SET expires_at = datetime(updated_at, '+864000 seconds')
+	WHERE state = 'subscribed' AND expires_at IS NULL;`,
+	// Items marked read by a scoped bulk mark rather than one by one, left
+	// out of reading statistics
+	`ALTER TABLE feeds_items ADD COLUMN read_in_bulk BOOLEAN NOT NULL DEFAULT FALSE;`,
+}
+
+// migrationBackfills run in the same transaction right after the migration
+// with the given number, for data that SQL alone cannot compute.
+var migrationBackfills = map[int]func(ctx context.Context, tx *sql.Tx) error{
+	8: backfillFeedItemKeys,
+}
+
+func (s *service) migrate() error {
+	ctx := context.Background()
+
+	// Table rebuilds need foreign keys disabled, and the pragma only applies
+	// to the connection it runs on, so every migration shares one connection.
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var version int
+	if err := conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	if version >= len(migrations) {
+		return nil
+	}
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys=OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys=ON")
+
+	// Renames would otherwise reparse the whole schema, including search
+	// triggers that cannot be loaded when SQLite was built without FTS5
+	if _, err := conn.ExecContext(ctx, "PRAGMA legacy_alter_table=ON"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA legacy_alter_table=OFF")
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("applying migration %d: %w", i+1, err)
+		}
+
+		if backfill, ok := migrationBackfills[i+1]; ok {
+			if err := backfill(ctx, tx); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("backfilling migration %d: %w", i+1, err)
+			}
+		}
+
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("updating schema version: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("committing migration %d: %w", i+1, err)
+		}
+
+		log.Printf("Applied database migration %d", i+1)
+	}
+
+	return nil
+}
@@ -0,0 +1,296 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"synthesis/internal/models"
+	"unicode"
+)
+
+var ErrSearchUnavailable = errors.New("search is not available, build with -tags sqlite_fts5")
+
+// searchIndex describes an FTS5 table kept in sync with a content table
+// through triggers. Tables with an integer primary key are indexed as
+// external content on it. Tables keyed on text have a rowid that VACUUM may
+// renumber, their index stores its own copy of the text along with the key.
+type searchIndex struct {
+	table   string
+	rowid   string
+	key     string
+	columns []string
+}
+
+var searchIndexes = []searchIndex{
+	{table: "feeds_items", rowid: "id", columns: []string{"title", "description", "content"}},
+	{table: "articles", key: "id", columns: []string{"title", "text_content"}},
+	{table: "notes", key: "id", columns: []string{"title", "content"}},
+}
+
+// Tables that were indexed once and no longer are
+var droppedSearchIndexes = []string{"emails"}
+
+// initSearch creates the full-text indexes when SQLite was built with FTS5.
+// Without it the sync triggers are dropped so writes keep working, and the
+// indexes are rebuilt the next time the triggers get created.
+func (s *service) initSearch() error {
+	var enabled bool
+	if err := s.db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled); err != nil {
+		return err
+	}
+
+	for _, index := range searchIndexes {
+		if !enabled {
+			if err := s.dropSearchTriggers(index.table); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := s.createSearchIndex(index); err != nil {
+			return fmt.Errorf("creating search index for %s: %w", index.table, err)
+		}
+	}
+
+	// Dropping an FTS5 table needs the module, which is why this is not a
+	// migration: without it only the triggers go, and the table on a later
+	// start with FTS5
+	for _, table := range droppedSearchIndexes {
+		if err := s.dropSearchTriggers(table); err != nil {
+			return err
+		}
+		if enabled {
+			if _, err := s.db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s_fts", table)); err != nil {
+				return fmt.Errorf("dropping search index for %s: %w", table, err)
+			}
+		}
+	}
+
+	if !enabled {
+		log.Println("SQLite was built without FTS5, search is disabled")
+	}
+	s.searchEnabled = enabled
+
+	return nil
+}
+
+func (s *service) dropSearchTriggers(table string) error {
+	for _, event := range []string{"ai", "ad", "au"} {
+		if _, err := s.db.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s_fts_%s", table, event)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) createSearchIndex(index searchIndex) error {
+	fts := index.table + "_fts"
+	columns := strings.Join(index.columns, ", ")
+
+	var newValues, oldValues []string
+	for _, column := range index.columns {
+		newValues = append(newValues, "new."+column)
+		oldValues = append(oldValues, "old."+column)
+	}
+
+	var triggers int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE ?", fts+"_%").Scan(&triggers)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var statements []string
+	if index.key == "" {
+		statements = []string{
+			fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(%s, content='%s', content_rowid='%s', tokenize='porter unicode61')`,
+				fts, columns, index.table, index.rowid),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_ai AFTER INSERT ON %[2]s BEGIN
+				INSERT INTO %[1]s(rowid, %[3]s) VALUES (new.%[4]s, %[5]s);
+			END`, fts, index.table, columns, index.rowid, strings.Join(newValues, ", ")),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_ad AFTER DELETE ON %[2]s BEGIN
+				INSERT INTO %[1]s(%[1]s, rowid, %[3]s) VALUES ('delete', old.%[4]s, %[5]s);
+			END`, fts, index.table, columns, index.rowid, strings.Join(oldValues, ", ")),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_au AFTER UPDATE OF %[3]s ON %[2]s BEGIN
+				INSERT INTO %[1]s(%[1]s, rowid, %[3]s) VALUES ('delete', old.%[4]s, %[5]s);
+				INSERT INTO %[1]s(rowid, %[3]s) VALUES (new.%[4]s, %[6]s);
+			END`, fts, index.table, columns, index.rowid, strings.Join(oldValues, ", "), strings.Join(newValues, ", ")),
+		}
+
+		// Rows written while the triggers were missing are not indexed
+		if triggers < 3 {
+			statements = append(statements, fmt.Sprintf(`INSERT INTO %[1]s(%[1]s) VALUES ('rebuild')`, fts))
+		}
+	} else {
+		// Indexes of text keyed tables used to be external content on their
+		// rowid, they are replaced along with their triggers
+		var stale bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE name = ? AND sql LIKE '%content_rowid%')", fts).Scan(&stale)
+		if err != nil {
+			return err
+		}
+		if stale {
+			statements = append(statements, "DROP TABLE "+fts)
+			for _, event := range []string{"ai", "ad", "au"} {
+				statements = append(statements, fmt.Sprintf("DROP TRIGGER IF EXISTS %s_%s", fts, event))
+			}
+			triggers = 0
+		}
+
+		statements = append(statements,
+			fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(%s UNINDEXED, %s, tokenize='porter unicode61')`,
+				fts, index.key, columns),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_ai AFTER INSERT ON %[2]s BEGIN
+				INSERT INTO %[1]s(%[3]s, %[4]s) VALUES (new.%[3]s, %[5]s);
+			END`, fts, index.table, index.key, columns, strings.Join(newValues, ", ")),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_ad AFTER DELETE ON %[2]s BEGIN
+				DELETE FROM %[1]s WHERE %[3]s = old.%[3]s;
+			END`, fts, index.table, index.key),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_au AFTER UPDATE OF %[3]s, %[4]s ON %[2]s BEGIN
+				DELETE FROM %[1]s WHERE %[3]s = old.%[3]s;
+				INSERT INTO %[1]s(%[3]s, %[4]s) VALUES (new.%[3]s, %[5]s);
+			END`, fts, index.table, index.key, columns, strings.Join(newValues, ", ")),
+		)
+
+		// Rows written while the triggers were missing are not indexed
+		if triggers < 3 {
+			statements = append(statements,
+				"DELETE FROM "+fts,
+				fmt.Sprintf(`INSERT INTO %[1]s(%[2]s, %[3]s) SELECT %[2]s, %[3]s FROM %[4]s`, fts, index.key, columns, index.table),
+			)
+		}
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Search runs query against the full-text indexes of the requested resource
+// types and returns the best hits of each, scoped to the user. Emails are left
+// out, nothing ties a recipient alias to a user yet.
+func (s *service) Search(ctx context.Context, userId string, query string, types []string, limit int) (*models.SearchResults, error) {
+	if !s.searchEnabled {
+		return nil, ErrSearchUnavailable
+	}
+
+	match := ftsQuery(query)
+	results := &models.SearchResults{
+		FeedItems: make([]*models.SearchHit, 0),
+		Articles:  make([]*models.SearchHit, 0),
+		Notes:     make([]*models.SearchHit, 0),
+	}
+	if match == "" {
+		return results, nil
+	}
+
+	searches := []struct {
+		resource string
+		fts      string
+		target   *[]*models.SearchHit
+		query    string
+	}{
+		{
+			resource: "feedItems",
+			fts:      "feeds_items_fts",
+			target:   &results.FeedItems,
+			query: `
+				SELECT CAST(t.id AS TEXT), t.title, COALESCE(snippet(feeds_items_fts, -1, '<mark>', '</mark>', '…', 16), ''), t.link,
+					t.published_parsed, feeds_items_fts.rank
+				FROM feeds_items_fts
+				JOIN feeds_items t ON t.id = feeds_items_fts.rowid
+				WHERE feeds_items_fts MATCH ? AND t.user_id = ?`,
+		},
+		{
+			resource: "articles",
+			fts:      "articles_fts",
+			target:   &results.Articles,
+			query: `
+				SELECT t.id, t.title, COALESCE(snippet(articles_fts, -1, '<mark>', '</mark>', '…', 16), ''), t.url,
+					t.scraped_at, articles_fts.rank
+				FROM articles_fts
+				JOIN articles t ON t.id = articles_fts.id
+				WHERE articles_fts MATCH ? AND t.user_id = ?`,
+		},
+		{
+			resource: "notes",
+			fts:      "notes_fts",
+			target:   &results.Notes,
+			query: `
+				SELECT t.id, t.title, COALESCE(snippet(notes_fts, -1, '<mark>', '</mark>', '…', 16), ''), NULL,
+					t.updated_at, notes_fts.rank
+				FROM notes_fts
+				JOIN notes t ON t.id = notes_fts.id
+				WHERE notes_fts MATCH ? AND t.user_id = ? AND t.deleted = FALSE`,
+		},
+	}
+
+	for _, search := range searches {
+		if !searchesResource(types, search.resource) {
+			continue
+		}
+
+		query := search.query + " ORDER BY " + search.fts + ".rank LIMIT ?"
+
+		rows, err := s.db.QueryContext(ctx, query, match, userId, limit)
+		if err != nil {
+			return nil, fmt.Errorf("searching %s: %w", search.resource, err)
+		}
+
+		for rows.Next() {
+			hit := &models.SearchHit{Type: search.resource}
+			if err := rows.Scan(&hit.Id, &hit.Title, &hit.Snippet, &hit.Url, &hit.Date, &hit.Rank); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan search hit: %w", err)
+			}
+			*search.target = append(*search.target, hit)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("searching %s: %w", search.resource, err)
+		}
+	}
+
+	return results, nil
+}
+
+func searchesResource(types []string, resource string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if t == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// ftsQuery turns free text into an FTS5 query that matches every word, the
+// last one as a prefix, so user input can never be a syntax error.
+func ftsQuery(query string) string {
+	var words []string
+	for _, word := range strings.Fields(query) {
+		// Punctuation only words produce no tokens and would match nothing
+		if !strings.ContainsFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+			continue
+		}
+		words = append(words, `"`+strings.ReplaceAll(word, `"`, `""`)+`"`)
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
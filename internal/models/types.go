@@ -58,15 +58,20 @@ type Article struct {
 }
 
 type FeedSource struct {
-	FeedLink        string    `json:"feedLink"`
-	Link            *string   `json:"link,omitempty"`
-	UserId          string    `json:"userId"`
-	UpdateFrequency string    `json:"updateFrequency"`
-	LastFetch       time.Time `json:"lastFetch,omitempty"`
-	Active          bool      `json:"active"`
-	FailureCount    int       `json:"failureCount"`
-	CreatedAt       time.Time `json:"createdAt"`
-	UpdatedAt       time.Time `json:"updatedAt"`
+	FeedLink        string     `json:"feedLink"`
+	Link            *string    `json:"link,omitempty"`
+	UserId          string     `json:"userId"`
+	UpdateFrequency string     `json:"updateFrequency"`
+	LastFetch       time.Time  `json:"lastFetch,omitempty"`
+	Active          bool       `json:"active"`
+	FailureCount    int        `json:"failureCount"`
+	ETag            *string    `json:"etag,omitempty"`
+	LastModified    *string    `json:"lastModified,omitempty"`
+	LastError       *string    `json:"lastError,omitempty"`
+	LastErrorAt     *time.Time `json:"lastErrorAt,omitempty"`
+	ExtractContent  bool       `json:"extractContent"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
 }
 
 type Feed struct {
@@ -75,7 +80,7 @@ type Feed struct {
 	UserId        string     `json:"userId"`
 	Title         *string    `json:"title,omitempty"`
 	Description   *string    `json:"description,omitempty"`
-	Label		  *string    `json:"label,omitempty"`
+	Label         *string    `json:"label,omitempty"`
 	ImageUrl      *string    `json:"imageUrl,omitempty"`
 	ImageTitle    *string    `json:"imageTitle,omitempty"`
 	Updated       *string    `json:"updated,omitempty"`
@@ -86,49 +91,298 @@ type Feed struct {
 }
 
 type FeedItem struct {
-	Id              int64      `json:"id"`
-	UserId          string     `json:"userId,omitempty"`
+	Id              int64               `json:"id"`
+	UserId          string              `json:"userId,omitempty"`
+	Title           *string             `json:"title,omitempty"`
+	Description     *string             `json:"description,omitempty"`
+	Content         *string             `json:"content,omitempty"`
+	FeedLink        string              `json:"feedLink,omitempty"`
+	Link            *string             `json:"link,omitempty"`
+	Author          *string             `json:"author,omitempty"`
+	Label           *string             `json:"label,omitempty"`
+	Authors         []FeedItemAuthor    `json:"authors,omitempty"`
+	Categories      []string            `json:"categories,omitempty"`
+	Enclosures      []FeedItemEnclosure `json:"enclosures,omitempty"`
+	ImageUrl        *string             `json:"imageUrl,omitempty"`
+	ImageTitle      *string             `json:"imageTitle,omitempty"`
+	Published       *string             `json:"published,omitempty"`
+	PublishedParsed *time.Time          `json:"publishedParsed,omitempty"`
+	Updated         *string             `json:"updated,omitempty"`
+	UpdatedParsed   *time.Time          `json:"updatedParsed,omitempty"`
+	GUID            *string             `json:"guid,omitempty"`
+	Read            bool                `json:"read"`
+	Starred         bool                `json:"starred"`
+	ArticleId       *string             `json:"articleId,omitempty"`
+	Revised         bool                `json:"revised"`
+	RevisedAt       *time.Time          `json:"revisedAt,omitempty"`
+	NormalizedLink  *string             `json:"-"`
+	ContentHash     *string             `json:"-"`
+	CreatedAt       time.Time           `json:"createdAt"`
+	UpdatedAt       time.Time           `json:"updatedAt"`
+}
+
+type FeedSubscription struct {
+	FeedLink        string     `json:"feedLink"`
+	Link            *string    `json:"link,omitempty"`
 	Title           *string    `json:"title,omitempty"`
 	Description     *string    `json:"description,omitempty"`
-	Content         *string    `json:"content,omitempty"`
-	FeedLink        string     `json:"feedLink,omitempty"`
-	Link            *string    `json:"link,omitempty"`
+	Label           *string    `json:"label,omitempty"`
 	ImageUrl        *string    `json:"imageUrl,omitempty"`
-	ImageTitle      *string    `json:"imageTitle,omitempty"`
-	Published       *string    `json:"published,omitempty"`
-	PublishedParsed *time.Time `json:"publishedParsed,omitempty"`
-	Updated         *string    `json:"updated,omitempty"`
-	UpdatedParsed   *time.Time `json:"updatedParsed,omitempty"`
-	GUID            *string    `json:"guid,omitempty"`
-	Read            bool       `json:"read"`
-	Starred         bool       `json:"starred"`
+	FeedType        *string    `json:"feedType,omitempty"`
+	UpdateFrequency string     `json:"updateFrequency"`
+	LastFetch       time.Time  `json:"lastFetch"`
+	Active          bool       `json:"active"`
+	FailureCount    int        `json:"failureCount"`
+	LastError       *string    `json:"lastError,omitempty"`
+	LastErrorAt     *time.Time `json:"lastErrorAt,omitempty"`
+	ExtractContent  bool       `json:"extractContent"`
+	UnreadCount     int        `json:"unreadCount"`
 	CreatedAt       time.Time  `json:"createdAt"`
-	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+type FeedSubscriptionUpdate struct {
+	Title           *string `json:"title"`
+	Label           *string `json:"label"`
+	Active          *bool   `json:"active"`
+	UpdateFrequency *string `json:"updateFrequency"`
+	ExtractContent  *bool   `json:"extractContent"`
+}
+
+type FeedItemsFilter struct {
+	FeedLink        string
+	Label           string
+	UnreadOnly      bool
+	StarredOnly     bool
+	Archived        bool
+	PublishedAfter  *time.Time
+	PublishedBefore *time.Time
+	Order           string
+	Limit           int
+	Offset          int
+	Cursor          string
+}
+
+type MarkReadScope struct {
+	FeedLink  string     `json:"feedLink"`
+	Label     string     `json:"label"`
+	Ids       []int64    `json:"ids"`
+	OlderThan *time.Time `json:"olderThan"`
+}
+
+type RetentionPolicy struct {
+	UserId     string    `json:"userId"`
+	FeedLink   string    `json:"feedLink"`
+	MaxAgeDays int       `json:"maxAgeDays"`
+	Action     string    `json:"action"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type FeedRule struct {
+	Id        int64     `json:"id"`
+	UserId    string    `json:"userId"`
+	Name      string    `json:"name"`
+	FeedLink  *string   `json:"feedLink,omitempty"`
+	Field     string    `json:"field"`
+	MatchType string    `json:"matchType"`
+	Pattern   string    `json:"pattern"`
+	Action    string    `json:"action"`
+	Label     *string   `json:"label,omitempty"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// FeedRuleMatch is a stored item a rule would apply to.
+type FeedRuleMatch struct {
+	Id        int64      `json:"id"`
+	FeedLink  string     `json:"feedLink"`
+	Title     *string    `json:"title,omitempty"`
+	Link      *string    `json:"link,omitempty"`
+	Author    *string    `json:"author,omitempty"`
+	Published *time.Time `json:"publishedParsed,omitempty"`
+	Action    string     `json:"action"`
+}
+
+// SharedFeed is a public Atom/RSS feed of the user's starred items, the
+// items of a label, their saved articles or their public notes, reachable by
+// anyone knowing its token.
+type SharedFeed struct {
+	Token     string    `json:"token"`
+	UserId    string    `json:"userId"`
+	Source    string    `json:"source"`
+	Label     *string   `json:"label,omitempty"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebSubSubscription is the push subscription of a feed source to the
+// WebSub hub it advertises.
+type WebSubSubscription struct {
+	FeedLink     string     `json:"feedLink"`
+	Hub          string     `json:"hub"`
+	Topic        string     `json:"topic"`
+	CallbackId   string     `json:"-"`
+	Secret       string     `json:"-"`
+	State        string     `json:"state"`
+	LeaseSeconds *int       `json:"leaseSeconds,omitempty"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+	LastError    *string    `json:"lastError,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+type FeedItemCounts struct {
+	Total   int `json:"total"`
+	Unread  int `json:"unread"`
+	Starred int `json:"starred"`
+}
+
+type FeedCounts struct {
+	FeedLink string  `json:"feedLink"`
+	Title    *string `json:"title,omitempty"`
+	FeedItemCounts
+}
+
+type LabelCounts struct {
+	Label string `json:"label"`
+	FeedItemCounts
+}
+
+type DailyReads struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+type FeedReads struct {
+	FeedLink string  `json:"feedLink"`
+	Title    *string `json:"title,omitempty"`
+	Count    int     `json:"count"`
+}
+
+// FeedStats aggregates the user's feed items. Counts leave out archived
+// items, like the default item listing.
+type FeedStats struct {
+	Overall     FeedItemCounts `json:"overall"`
+	Feeds       []FeedCounts   `json:"feeds"`
+	Labels      []LabelCounts  `json:"labels"`
+	Days        int            `json:"days"`
+	ReadsPerDay []DailyReads   `json:"readsPerDay"`
+	MostRead    []FeedReads    `json:"mostRead"`
+}
+
+type FeedRedirect struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Location string `json:"location"`
+}
+
+type FeedItemPreview struct {
+	Title     *string    `json:"title,omitempty"`
+	Link      *string    `json:"link,omitempty"`
+	Published *time.Time `json:"published,omitempty"`
+}
+
+// FeedItemsDelta compares a fetched feed with what is stored for the user.
+// Known items are stored already and unchanged, repeated within the feed, or
+// older than the retention policy keeps.
+type FeedItemsDelta struct {
+	New      int               `json:"new"`
+	Revised  int               `json:"revised"`
+	Filtered int               `json:"filtered"`
+	Known    int               `json:"known"`
+	Stored   int               `json:"stored"`
+	NewItems []FeedItemPreview `json:"newItems"`
+}
+
+// FeedDiagnostics reports a fetch of a feed made on demand, step by step,
+// along with the state of its source.
+type FeedDiagnostics struct {
+	FeedLink      string          `json:"feedLink"`
+	Source        *FeedSource     `json:"source"`
+	CheckedAt     time.Time       `json:"checkedAt"`
+	DurationMs    int64           `json:"durationMs"`
+	Status        int             `json:"status,omitempty"`
+	Redirects     []FeedRedirect  `json:"redirects"`
+	FinalURL      string          `json:"finalUrl,omitempty"`
+	ContentType   string          `json:"contentType,omitempty"`
+	ContentLength int             `json:"contentLength"`
+	FeedType      string          `json:"feedType,omitempty"`
+	FeedVersion   string          `json:"feedVersion,omitempty"`
+	FeedTitle     string          `json:"feedTitle,omitempty"`
+	Hub           string          `json:"hub,omitempty"`
+	FetchError    string          `json:"fetchError,omitempty"`
+	ParseError    string          `json:"parseError,omitempty"`
+	ItemCount     int             `json:"itemCount"`
+	Delta         *FeedItemsDelta `json:"delta,omitempty"`
+}
+
+type RetentionSummary struct {
+	Deleted  int64 `json:"deleted"`
+	Archived int64 `json:"archived"`
+}
+
+type FeedRefreshError struct {
+	FeedLink string `json:"feedLink"`
+	Error    string `json:"error"`
+}
+
+type FeedRefreshSummary struct {
+	Sources      int                `json:"sources"`
+	Fetched      int                `json:"fetched"`
+	NotModified  int                `json:"notModified"`
+	Failed       int                `json:"failed"`
+	BackingOff   int                `json:"backingOff,omitempty"`
+	NewItems     int                `json:"newItems"`
+	RevisedItems int                `json:"revisedItems"`
+	Errors       []FeedRefreshError `json:"errors,omitempty"`
+	Duration     string             `json:"duration"`
+}
+
+type FeedItemAuthor struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// FeedItemEnclosure is a file attached to an item, such as a podcast episode.
+// Length is in bytes, zero when the feed does not say.
+type FeedItemEnclosure struct {
+	URL    string `json:"url"`
+	Type   string `json:"type,omitempty"`
+	Length int64  `json:"length,omitempty"`
 }
 
 type FeedItemWithFeed struct {
-	Id              int64      `json:"id"`
-	UserId          string     `json:"userId,omitempty"`
-	Title           *string    `json:"title,omitempty"`
-	Description     *string    `json:"description,omitempty"`
-	Content         *string    `json:"content,omitempty"`
-	FeedLink        string     `json:"feedLink,omitempty"`
-	Link            *string    `json:"link,omitempty"`
-	ImageUrl        *string    `json:"imageUrl,omitempty"`
-	ImageTitle      *string    `json:"imageTitle,omitempty"`
-	Published       *string    `json:"published,omitempty"`
-	PublishedParsed *time.Time `json:"publishedParsed,omitempty"`
-	Updated         *string    `json:"updated,omitempty"`
-	UpdatedParsed   *time.Time `json:"updatedParsed,omitempty"`
-	GUID            *string    `json:"guid,omitempty"`
-	Read            bool       `json:"read"`
-	Starred         bool       `json:"starred"`
-	CreatedAt       time.Time  `json:"createdAt"`
-	UpdatedAt       time.Time  `json:"updatedAt"`
+	Id              int64               `json:"id"`
+	UserId          string              `json:"userId,omitempty"`
+	Title           *string             `json:"title,omitempty"`
+	Description     *string             `json:"description,omitempty"`
+	Content         *string             `json:"content,omitempty"`
+	FeedLink        string              `json:"feedLink,omitempty"`
+	Link            *string             `json:"link,omitempty"`
+	Author          *string             `json:"author,omitempty"`
+	Label           *string             `json:"label,omitempty"`
+	Authors         []FeedItemAuthor    `json:"authors,omitempty"`
+	Categories      []string            `json:"categories,omitempty"`
+	Enclosures      []FeedItemEnclosure `json:"enclosures,omitempty"`
+	ImageUrl        *string             `json:"imageUrl,omitempty"`
+	ImageTitle      *string             `json:"imageTitle,omitempty"`
+	Published       *string             `json:"published,omitempty"`
+	PublishedParsed *time.Time          `json:"publishedParsed,omitempty"`
+	Updated         *string             `json:"updated,omitempty"`
+	UpdatedParsed   *time.Time          `json:"updatedParsed,omitempty"`
+	GUID            *string             `json:"guid,omitempty"`
+	Read            bool                `json:"read"`
+	Starred         bool                `json:"starred"`
+	ArticleId       *string             `json:"articleId,omitempty"`
+	Revised         bool                `json:"revised"`
+	RevisedAt       *time.Time          `json:"revisedAt,omitempty"`
+	CreatedAt       time.Time           `json:"createdAt"`
+	UpdatedAt       time.Time           `json:"updatedAt"`
 	Feed            struct {
 		Title       *string `json:"title,omitempty"`
 		Description *string `json:"description,omitempty"`
-		Label		*string    `json:"label,omitempty"`
+		Label       *string `json:"label,omitempty"`
 		ImageUrl    *string `json:"imageUrl,omitempty"`
 		FeedType    *string `json:"feedType,omitempty"`
 	} `json:"feed"`
@@ -172,3 +426,19 @@ type Email struct {
 	CreatedAt       time.Time `json:"createdAt"`
 	UpdatedAt       time.Time `json:"updatedAt"`
 }
+
+type SearchHit struct {
+	Type    string     `json:"type"`
+	Id      string     `json:"id"`
+	Title   *string    `json:"title,omitempty"`
+	Snippet string     `json:"snippet"`
+	Url     *string    `json:"url,omitempty"`
+	Date    *time.Time `json:"date,omitempty"`
+	Rank    float64    `json:"rank"`
+}
+
+type SearchResults struct {
+	FeedItems []*SearchHit `json:"feedItems"`
+	Articles  []*SearchHit `json:"articles"`
+	Notes     []*SearchHit `json:"notes"`
+}
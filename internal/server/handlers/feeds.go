@@ -2,23 +2,45 @@ package handlers
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"synthesis/internal/database"
 	"synthesis/internal/models"
+	scraper "synthesis/internal/services/article-scraper"
+	fetcher "synthesis/internal/services/feed-fetcher"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/mmcdole/gofeed"
+)
+
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+
+	// Shortest interval between two refreshes a user asks for
+	manualRefreshCooldown = 2 * time.Minute
+
+	// Upper bound for the fetch of a diagnostics request, well below the
+	// server's write timeout so the report still gets out
+	diagnoseFeedTimeout = 20 * time.Second
+
+	// Most item ids one mark-as-read request takes, each is bound to its
+	// own query parameter
+	maxMarkReadIds = 500
 )
 
 type FeedsHandler struct {
-	db database.Service
+	db   database.Service
+	jobs *jobRegistry
 }
 
 func NewFeedsHandler(db database.Service) *FeedsHandler {
-	return &FeedsHandler{db: db}
+	return &FeedsHandler{db: db, jobs: newJobRegistry()}
 }
 
 func (h *FeedsHandler) CreateFeedHandler(c *gin.Context) {
@@ -32,32 +54,75 @@ func (h *FeedsHandler) CreateFeedHandler(c *gin.Context) {
 
 	label := c.Query("label")
 
+	updateFrequency := c.DefaultQuery("updateFrequency", database.DefaultUpdateFrequency)
+	if _, err := database.ParseUpdateFrequency(updateFrequency); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	exists, err := h.db.FeedExists(ctx, feedLink, userId)
+	feedModel, itemsCount, err := h.subscribeToFeed(ctx, userId, feedLink, label, updateFrequency, false)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check feed existence"})
+		switch {
+		case errors.Is(err, errFeedExists):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "feed already exists"})
+		case errors.Is(err, errFeedUnavailable):
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to parse feed"})
+		default:
+			log.Printf("Error subscribing to %s: %v", feedLink, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create feed"})
+		}
 		return
 	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"feed":       feedModel,
+		"itemsCount": itemsCount,
+	})
+}
+
+var (
+	errFeedExists      = errors.New("feed already exists")
+	errFeedUnavailable = errors.New("failed to parse feed")
+)
+
+// subscribeToFeed fetches feedLink and stores it as a new subscription of the
+// user along with its current items, which start out as read. When feedLink
+// is not a feed the website's first feed is used instead, unless feedLink was
+// itself discovered.
+func (h *FeedsHandler) subscribeToFeed(ctx context.Context, userId string, feedLink string, label string, updateFrequency string, discovered bool) (*models.Feed, int, error) {
+	exists, err := h.db.FeedExists(ctx, feedLink, userId)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to check feed existence: %w", err)
+	}
 	if exists {
-		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "feed already exists"})
-		return
+		return nil, 0, errFeedExists
 	}
 
-	fp := gofeed.NewParser()
-	feed, err := fp.ParseURLWithContext(feedLink, ctx)
-
+	result, err := fetcher.Fetch(ctx, feedLink, "", "")
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to parse feed"})
-		return
+		// Most people paste a website rather than its feed, so fall back to
+		// the first feed the website advertises. A discovered feed that fails
+		// too is not discovered from again, pages could point at each other.
+		if discovered {
+			return nil, 0, fmt.Errorf("%w: %v", errFeedUnavailable, err)
+		}
+		candidates, errDiscover := fetcher.Discover(ctx, feedLink)
+		if errDiscover != nil || len(candidates) == 0 || candidates[0].FeedLink == feedLink {
+			return nil, 0, fmt.Errorf("%w: %v", errFeedUnavailable, err)
+		}
+		return h.subscribeToFeed(ctx, userId, candidates[0].FeedLink, label, updateFrequency, true)
 	}
 
+	feed := result.Feed
+
 	feedSource := &models.FeedSource{
 		FeedLink:        feedLink,
 		Link:            &feed.Link,
 		UserId:          userId,
-		UpdateFrequency: "1h",
+		UpdateFrequency: updateFrequency,
 		LastFetch:       time.Now(),
 		Active:          true,
 		FailureCount:    0,
@@ -71,7 +136,7 @@ func (h *FeedsHandler) CreateFeedHandler(c *gin.Context) {
 		UserId:        userId,
 		Title:         &feed.Title,
 		Description:   &feed.Description,
-		Label: 	   	   &label,
+		Label:         &label,
 		Updated:       &feed.Updated,
 		UpdatedParsed: feed.UpdatedParsed,
 		FeedType:      &feed.FeedType,
@@ -79,6 +144,13 @@ func (h *FeedsHandler) CreateFeedHandler(c *gin.Context) {
 		UpdatedAt:     time.Now(),
 	}
 
+	if result.ETag != "" {
+		feedSource.ETag = &result.ETag
+	}
+	if result.LastModified != "" {
+		feedSource.LastModified = &result.LastModified
+	}
+
 	if feed.Image != nil && feed.Image.URL != "" {
 		feedModel.ImageUrl = &feed.Image.URL
 		if feed.Image.Title != "" {
@@ -88,79 +160,96 @@ func (h *FeedsHandler) CreateFeedHandler(c *gin.Context) {
 
 	feedItems := make([]*models.FeedItem, 0)
 	for _, item := range feed.Items {
-		feedItem := &models.FeedItem{
-			UserId:          userId,
-			Title:           &item.Title,
-			Description:     &item.Description,
-			Content:         &item.Content,
-			FeedLink:        feedLink,
-			Link:            &item.Link,
-			Published:       &item.Published,
-			PublishedParsed: item.PublishedParsed,
-			Updated:         &item.Updated,
-			UpdatedParsed:   item.UpdatedParsed,
-			GUID:            &item.GUID,
-			Read:            true,
-			Starred:         false,
-			CreatedAt:       time.Now(),
-			UpdatedAt:       time.Now(),
-		}
-
-		if item.Image != nil && item.Image.URL != "" {
-			feedItem.ImageUrl = &item.Image.URL
-			if item.Image.Title != "" {
-				feedItem.ImageTitle = &item.Image.Title
-			}
-		}
+		feedItem := database.NewFeedItem(item, feedLink, userId)
+		// Items already published when subscribing are not news
+		feedItem.Read = true
 
 		feedItems = append(feedItems, feedItem)
 	}
 
 	err = h.db.CreateFeed(ctx, feedSource, feedModel, feedItems)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create feed"})
-		return
+		// A concurrent subscription to the same feed, such as another outline
+		// of an OPML import resolving to it, may have won the race
+		if exists, errExists := h.db.FeedExists(ctx, feedLink, userId); errExists == nil && exists {
+			return nil, 0, errFeedExists
+		}
+		return nil, 0, fmt.Errorf("failed to create feed: %w", err)
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"feed":       feedModel,
-		"itemsCount": len(feedItems),
-	})
+	// Feeds advertising a hub are pushed to us on top of being polled
+	if result.Hub != "" {
+		go func(ctx context.Context) {
+			if err := h.db.SubscribeWebSub(ctx, feedLink, result.Hub, result.Self); err != nil {
+				log.Printf("Error subscribing %s to hub %s: %v", feedLink, result.Hub, err)
+			}
+		}(context.WithoutCancel(ctx))
+	}
+
+	return feedModel, len(feedItems), nil
 }
 
 func (h *FeedsHandler) GetFeedItemsHandler(c *gin.Context) {
 	userId := c.GetString("userId")
 
-	limit := 50
-	offset := 0
+	filter := &models.FeedItemsFilter{
+		FeedLink:    c.Query("feedLink"),
+		Label:       c.Query("label"),
+		UnreadOnly:  c.Query("unread") == "true",
+		StarredOnly: c.Query("starred") == "true",
+		Archived:    c.Query("archived") == "true",
+		Order:       c.Query("order"),
+		Limit:       50,
+		Cursor:      c.Query("cursor"),
+	}
 
 	if limitStr := c.Query("limit"); limitStr != "" {
 		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
+			filter.Limit = min(l, 500)
 		}
 	}
 
+	// Kept for clients that do not page with cursors yet
 	if offsetStr := c.Query("offset"); offsetStr != "" {
 		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
+			filter.Offset = o
 		}
 	}
 
-	order := c.Query("order")
+	if filter.Order != "ASC" {
+		filter.Order = "DESC"
+	}
 
-	if order != "" {
-		if order != "ASC" && order != "DESC" {
-			order = "DESC"
+	for param, target := range map[string]**time.Time{
+		"publishedAfter":  &filter.PublishedAfter,
+		"publishedBefore": &filter.PublishedBefore,
+	} {
+		if value := c.Query(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 timestamp"})
+				return
+			}
+			*target = &t
 		}
 	}
 
-	items, err := h.db.GetFeedItems(c, userId, order, limit, offset)
+	items, nextCursor, err := h.db.GetFeedItems(c, userId, filter)
 	if err != nil {
-		fmt.Println(err)
+		if errors.Is(err, database.ErrInvalidCursor) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error fetching feed items: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch items"})
 		return
 	}
 
+	// The body stays a plain array, the next page is announced in a header
+	if nextCursor != "" {
+		c.Header("X-Next-Cursor", nextCursor)
+	}
+
 	c.JSON(http.StatusOK, items)
 }
 
@@ -175,9 +264,8 @@ func (h *FeedsHandler) DeleteFeedHandler(c *gin.Context) {
 	userId := c.GetString("userId")
 
 	err := h.db.DeleteFeed(c.Request.Context(), feedLink, userId)
-	fmt.Println(err)
-
 	if err != nil {
+		log.Printf("Error deleting feed %s: %v", feedLink, err)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to delete feed"})
 		return
 	}
@@ -219,14 +307,433 @@ func (h *FeedsHandler) UpdateFeedItemHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "post updated successfully"})
 }
 
+// GetFeedStatsHandler returns the caller's unread, starred and total counts
+// and their reading over the last days, 30 unless days is given.
+func (h *FeedsHandler) GetFeedStatsHandler(c *gin.Context) {
+	days := defaultStatsDays
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid days"})
+			return
+		}
+		days = min(parsed, maxStatsDays)
+	}
+
+	userId := c.GetString("userId")
+
+	stats, err := h.db.GetFeedStats(c.Request.Context(), userId, days)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch feed stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// SaveFeedItemHandler scrapes the item's link into a saved article and links
+// it back to the item. Saving an item twice returns the existing article.
+func (h *FeedsHandler) SaveFeedItemHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid feed item id"})
+		return
+	}
+
+	userId := c.GetString("userId")
+	ctx := c.Request.Context()
+
+	item, err := h.db.GetFeedItem(ctx, id, userId)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "feed item not found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch feed item"})
+		return
+	}
+
+	if item.ArticleId != nil {
+		// The article may have been deleted since, in which case it is saved again
+		if article, err := h.db.GetArticle(ctx, userId, *item.ArticleId); err == nil {
+			c.JSON(http.StatusOK, article)
+			return
+		}
+	}
+
+	if item.Link == nil || *item.Link == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "feed item has no link"})
+		return
+	}
+
+	article, err := scraper.GetArticleContext(ctx, *item.Link)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("failed to scrape article: %v", err)})
+		return
+	}
+
+	articleId, err := newArticleId()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to save article"})
+		return
+	}
+
+	article.Id = &articleId
+	article.UserId = &userId
+	if article.Title == nil {
+		article.Title = item.Title
+	}
+
+	if _, err := h.db.CreateArticle(ctx, &article); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to save article"})
+		return
+	}
+
+	if err := h.db.SetFeedItemArticle(ctx, id, userId, articleId); err != nil {
+		// Do not leave an orphaned article behind when the item vanished meanwhile
+		h.db.DeleteArticle(context.WithoutCancel(ctx), articleId, userId)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to link article to feed item"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, article)
+}
+
+// newArticleId returns a random version 4 UUID.
+func newArticleId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
 func (h *FeedsHandler) MarkAllFeedItemsAsReadHandler(c *gin.Context) {
 	userId := c.GetString("userId")
 
-	err := h.db.MarkAllFeedItemsAsRead(c.Request.Context(), userId)
+	updated, err := h.db.MarkFeedItemsAsRead(c.Request.Context(), userId, &models.MarkReadScope{})
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to update feed items"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "all feeds marked as read successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "all feeds marked as read successfully", "updated": updated})
+}
+
+// MarkFeedItemsAsReadHandler marks the items of a single feed, a label, an
+// explicit list of ids or the items older than a timestamp as read. Scopes
+// can be combined and narrow each other down.
+func (h *FeedsHandler) MarkFeedItemsAsReadHandler(c *gin.Context) {
+	var scope models.MarkReadScope
+	if err := c.ShouldBindJSON(&scope); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	if scope.FeedLink == "" && scope.Label == "" && len(scope.Ids) == 0 && scope.OlderThan == nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "one of feedLink, label, ids or olderThan is required"})
+		return
+	}
+	if len(scope.Ids) > maxMarkReadIds {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d ids can be marked at once", maxMarkReadIds)})
+		return
+	}
+
+	userId := c.GetString("userId")
+
+	updated, err := h.db.MarkFeedItemsAsRead(c.Request.Context(), userId, &scope)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to update feed items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+func (h *FeedsHandler) UpdateFeedFrequencyHandler(c *gin.Context) {
+	type UpdateFrequencyRequest struct {
+		FeedLink        string `json:"feedLink" binding:"required"`
+		UpdateFrequency string `json:"updateFrequency" binding:"required"`
+	}
+
+	var req UpdateFrequencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	if _, err := database.ParseUpdateFrequency(req.UpdateFrequency); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userId := c.GetString("userId")
+
+	err := h.db.UpdateFeedFrequency(c.Request.Context(), req.FeedLink, userId, req.UpdateFrequency)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "feed not found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to update feed frequency"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "feed frequency updated successfully"})
+}
+
+func (h *FeedsHandler) GetBrokenFeedsHandler(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	sources, err := h.db.GetBrokenFeedSources(c.Request.Context(), userId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch broken feeds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sources)
+}
+
+// RefreshFeedsHandler fetches the caller's feeds now, or only the one given
+// by feedLink. Fetching can take minutes, so the refresh runs in the
+// background and the response is a job to poll for its summary. Each user
+// gets one refresh per manualRefreshCooldown, and failing feeds waiting out
+// their backoff are skipped, so refreshing by hand cannot hammer a failing
+// publisher.
+func (h *FeedsHandler) RefreshFeedsHandler(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	sources, backingOff, err := h.db.GetUserRefreshSources(c.Request.Context(), userId, c.Query("feedLink"))
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "feed not found"})
+		case strings.Contains(err.Error(), "inactive"):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "feed is inactive, reactivate or resume it first"})
+		case strings.Contains(err.Error(), "backing off"):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "feed failed recently and is retried after a backoff, try again later"})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh feeds"})
+		}
+		return
+	}
+
+	j, started, wait, err := h.jobs.startExclusive(userId, "refresh", len(sources), manualRefreshCooldown)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh feeds"})
+		return
+	}
+	if j == nil {
+		retryAfter := int((wait + time.Second - 1) / time.Second)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "feeds were refreshed recently, try again later", "retryAfter": retryAfter})
+		return
+	}
+	// Already refreshing, the running job is the one to poll
+	if !started {
+		acceptJob(c, j)
+		return
+	}
+
+	go func() {
+		summary := h.db.RefreshFeedSources(context.Background(), sources, func() { h.jobs.advance(j.Id) })
+		summary.BackingOff = backingOff
+		h.jobs.finish(j.Id, summary, nil)
+	}()
+
+	acceptJob(c, j)
+}
+
+// DiagnoseFeedHandler fetches one of the caller's feeds and reports each step
+// of the fetch and what a refresh would store, without changing anything.
+func (h *FeedsHandler) DiagnoseFeedHandler(c *gin.Context) {
+	feedLink := c.Query("feedLink")
+	if feedLink == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "feedLink parameter is required"})
+		return
+	}
+
+	userId := c.GetString("userId")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), diagnoseFeedTimeout)
+	defer cancel()
+
+	report, err := h.db.DiagnoseFeed(ctx, userId, feedLink)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "feed not found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to diagnose feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *FeedsHandler) ReactivateFeedHandler(c *gin.Context) {
+	type ReactivateRequest struct {
+		FeedLink string `json:"feedLink" binding:"required"`
+	}
+
+	var req ReactivateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	userId := c.GetString("userId")
+
+	err := h.db.ReactivateFeedSource(c.Request.Context(), req.FeedLink, userId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to reactivate feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "feed reactivated successfully"})
+}
+
+func (h *FeedsHandler) DiscoverFeedsHandler(c *gin.Context) {
+	pageURL := c.Query("url")
+	if pageURL == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "url parameter is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 25*time.Second)
+	defer cancel()
+
+	candidates, err := fetcher.Discover(ctx, pageURL)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, candidates)
+}
+
+func (h *FeedsHandler) GetFeedSourcesHandler(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	subscriptions, err := h.db.GetFeedSubscriptions(c.Request.Context(), userId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch feeds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+func (h *FeedsHandler) UpdateFeedSourceHandler(c *gin.Context) {
+	type UpdateSourceRequest struct {
+		FeedLink string `json:"feedLink" binding:"required"`
+		models.FeedSubscriptionUpdate
+	}
+
+	var req UpdateSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	if req.UpdateFrequency != nil {
+		if _, err := database.ParseUpdateFrequency(*req.UpdateFrequency); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userId := c.GetString("userId")
+
+	err := h.db.UpdateFeedSubscription(c.Request.Context(), req.FeedLink, userId, &req.FeedSubscriptionUpdate)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "feed not found"})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to update feed"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "feed updated successfully"})
+}
+
+func (h *FeedsHandler) GetRetentionPoliciesHandler(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	policies, err := h.db.GetRetentionPolicies(c.Request.Context(), userId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch retention policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policies)
+}
+
+// UpsertRetentionPolicyHandler sets the retention policy of a feed, or the
+// user's default policy when feedLink is empty.
+func (h *FeedsHandler) UpsertRetentionPolicyHandler(c *gin.Context) {
+	type RetentionRequest struct {
+		FeedLink   string `json:"feedLink"`
+		MaxAgeDays int    `json:"maxAgeDays" binding:"required"`
+		Action     string `json:"action"`
+	}
+
+	var req RetentionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	if req.Action == "" {
+		req.Action = database.RetentionActionDelete
+	}
+	if req.MaxAgeDays <= 0 || (req.Action != database.RetentionActionDelete && req.Action != database.RetentionActionArchive) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "maxAgeDays must be positive and action either delete or archive"})
+		return
+	}
+
+	userId := c.GetString("userId")
+
+	if req.FeedLink != "" {
+		exists, err := h.db.FeedExists(c.Request.Context(), req.FeedLink, userId)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check feed existence"})
+			return
+		}
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "feed not found"})
+			return
+		}
+	}
+
+	policy := &models.RetentionPolicy{
+		UserId:     userId,
+		FeedLink:   req.FeedLink,
+		MaxAgeDays: req.MaxAgeDays,
+		Action:     req.Action,
+	}
+
+	if err := h.db.UpsertRetentionPolicy(c.Request.Context(), policy); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to save retention policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (h *FeedsHandler) DeleteRetentionPolicyHandler(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	err := h.db.DeleteRetentionPolicy(c.Request.Context(), userId, c.Query("feedLink"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "retention policy deleted successfully"})
 }
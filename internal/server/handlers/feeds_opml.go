@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"synthesis/internal/database"
+	"synthesis/internal/services/opml"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxOPMLSize = 5 << 20
+	// Number of feeds fetched at the same time during an import
+	opmlImportWorkers = 4
+)
+
+type opmlImportFailure struct {
+	FeedLink string `json:"feedLink"`
+	Error    string `json:"error"`
+}
+
+type opmlImportReport struct {
+	Imported []opml.Subscription `json:"imported"`
+	Skipped  []opml.Subscription `json:"skipped"`
+	Failed   []opmlImportFailure `json:"failed"`
+}
+
+// ImportOPMLHandler subscribes the user to every feed of an OPML document,
+// sent either as the "file" field of a multipart form or as the raw body.
+// Fetching every feed can take minutes, so the import runs in the background
+// and the response is a job to poll for its progress and report. A user runs
+// one import at a time.
+func (h *FeedsHandler) ImportOPMLHandler(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	// The limit covers the multipart form too, whose files are otherwise
+	// buffered whatever their size
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxOPMLSize)
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			if isBodyTooLarge(err) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "OPML file is too large"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "file field is required"})
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read OPML file"})
+			return
+		}
+		defer file.Close()
+		body = io.LimitReader(file, maxOPMLSize)
+	}
+
+	subscriptions, err := opml.Parse(body)
+	if err != nil {
+		if isBodyTooLarge(err) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "OPML file is too large"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := &opmlImportReport{
+		Imported: make([]opml.Subscription, 0),
+		Skipped:  make([]opml.Subscription, 0),
+		Failed:   make([]opmlImportFailure, 0),
+	}
+
+	// Outlines listing the same feed twice are imported once
+	unique := make([]opml.Subscription, 0, len(subscriptions))
+	seen := make(map[string]bool)
+	for _, subscription := range subscriptions {
+		if seen[subscription.FeedLink] {
+			report.Skipped = append(report.Skipped, subscription)
+			continue
+		}
+		seen[subscription.FeedLink] = true
+		unique = append(unique, subscription)
+	}
+
+	// One import per user at a time, each one already fetches with several
+	// workers
+	j, started, _, err := h.jobs.startExclusive(userId, "opml-import", len(unique), 0)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to start import"})
+		return
+	}
+	if !started {
+		c.Header("Location", "/feeds/jobs/"+j.Id)
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "an OPML import is already running", "jobId": j.Id})
+		return
+	}
+
+	go func() {
+		h.importOPML(userId, unique, j.Id, report)
+		h.jobs.finish(j.Id, report, nil)
+	}()
+
+	acceptJob(c, j)
+}
+
+func (h *FeedsHandler) importOPML(userId string, subscriptions []opml.Subscription, jobId string, report *opmlImportReport) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		workers = make(chan struct{}, opmlImportWorkers)
+	)
+
+	for _, subscription := range subscriptions {
+		wg.Add(1)
+		go func(subscription opml.Subscription) {
+			defer wg.Done()
+
+			workers <- struct{}{}
+			defer func() { <-workers }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			_, _, err := h.subscribeToFeed(ctx, userId, subscription.FeedLink, subscription.Label, database.DefaultUpdateFrequency, false)
+
+			mu.Lock()
+			switch {
+			case errors.Is(err, errFeedExists):
+				report.Skipped = append(report.Skipped, subscription)
+			case err != nil:
+				report.Failed = append(report.Failed, opmlImportFailure{FeedLink: subscription.FeedLink, Error: err.Error()})
+			default:
+				report.Imported = append(report.Imported, subscription)
+			}
+			mu.Unlock()
+
+			h.jobs.advance(jobId)
+		}(subscription)
+	}
+
+	wg.Wait()
+}
+
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+func (h *FeedsHandler) ExportOPMLHandler(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	feeds, err := h.db.GetFeeds(c.Request.Context(), userId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch feeds"})
+		return
+	}
+
+	subscriptions := make([]opml.Subscription, 0, len(feeds))
+	for _, feed := range feeds {
+		subscription := opml.Subscription{FeedLink: feed.FeedLink}
+		if feed.Title != nil {
+			subscription.Title = *feed.Title
+		}
+		if feed.Link != nil {
+			subscription.Link = *feed.Link
+		}
+		if feed.Label != nil {
+			subscription.Label = *feed.Label
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="synthesis-subscriptions.opml"`)
+	c.Header("Content-Type", "text/x-opml; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	if err := opml.Write(c.Writer, "Synthesis subscriptions", subscriptions); err != nil {
+		c.Error(err)
+	}
+}
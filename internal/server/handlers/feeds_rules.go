@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"synthesis/internal/models"
+	"synthesis/internal/services/rules"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDryRunLimit = 200
+	maxDryRunLimit     = 1000
+)
+
+type feedRuleRequest struct {
+	Name      string  `json:"name"`
+	FeedLink  *string `json:"feedLink"`
+	Field     string  `json:"field"`
+	MatchType string  `json:"matchType"`
+	Pattern   string  `json:"pattern"`
+	Action    string  `json:"action"`
+	Label     *string `json:"label"`
+	Enabled   *bool   `json:"enabled"`
+}
+
+// bindFeedRule reads a rule from the request body, defaulting to a keyword
+// match on every text field, and aborts the request when it is invalid.
+func (h *FeedsHandler) bindFeedRule(c *gin.Context) (*models.FeedRule, bool) {
+	var req feedRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return nil, false
+	}
+
+	rule := &models.FeedRule{
+		UserId:    c.GetString("userId"),
+		Name:      strings.TrimSpace(req.Name),
+		FeedLink:  req.FeedLink,
+		Field:     req.Field,
+		MatchType: req.MatchType,
+		Pattern:   req.Pattern,
+		Action:    req.Action,
+		Label:     req.Label,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+	}
+	if rule.Field == "" {
+		rule.Field = rules.FieldAny
+	}
+	if rule.MatchType == "" {
+		rule.MatchType = rules.MatchKeyword
+	}
+	if rule.FeedLink != nil && *rule.FeedLink == "" {
+		rule.FeedLink = nil
+	}
+
+	if err := rules.Validate(rule); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if rule.FeedLink != nil {
+		exists, err := h.db.FeedExists(c.Request.Context(), *rule.FeedLink, rule.UserId)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check feed existence"})
+			return nil, false
+		}
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "feed not found"})
+			return nil, false
+		}
+	}
+
+	return rule, true
+}
+
+func (h *FeedsHandler) GetFeedRulesHandler(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	feedRules, err := h.db.GetFeedRules(c.Request.Context(), userId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch feed rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, feedRules)
+}
+
+func (h *FeedsHandler) CreateFeedRuleHandler(c *gin.Context) {
+	rule, ok := h.bindFeedRule(c)
+	if !ok {
+		return
+	}
+	if rule.Name == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	rule, err := h.db.CreateFeedRule(c.Request.Context(), rule)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create feed rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *FeedsHandler) UpdateFeedRuleHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid feed rule id"})
+		return
+	}
+
+	rule, ok := h.bindFeedRule(c)
+	if !ok {
+		return
+	}
+	if rule.Name == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	rule.Id = id
+
+	if err := h.db.UpdateFeedRule(c.Request.Context(), rule); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "feed rule not found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to update feed rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "feed rule updated successfully"})
+}
+
+func (h *FeedsHandler) DeleteFeedRuleHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid feed rule id"})
+		return
+	}
+
+	userId := c.GetString("userId")
+
+	if err := h.db.DeleteFeedRule(c.Request.Context(), id, userId); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "feed rule not found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to delete feed rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "feed rule deleted successfully"})
+}
+
+// DryRunFeedRuleHandler returns the user's recent items the rule in the body
+// would apply to, without saving the rule or changing any item.
+func (h *FeedsHandler) DryRunFeedRuleHandler(c *gin.Context) {
+	limit := defaultDryRunLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = min(parsed, maxDryRunLimit)
+	}
+
+	rule, ok := h.bindFeedRule(c)
+	if !ok {
+		return
+	}
+
+	matches, err := h.db.DryRunFeedRule(c.Request.Context(), rule.UserId, rule, limit)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to test feed rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"limit": limit, "matches": matches})
+}
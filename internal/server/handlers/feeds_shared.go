@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"synthesis/internal/models"
+	"synthesis/internal/services/syndication"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sharedSourceStarred  = "starred"
+	sharedSourceLabel    = "label"
+	sharedSourceArticles = "articles"
+	sharedSourceNotes    = "notes"
+
+	// Number of entries a shared feed renders, newest first
+	sharedFeedLimit = 50
+)
+
+type sharedFeedRequest struct {
+	Source string  `json:"source"`
+	Label  *string `json:"label"`
+	Title  string  `json:"title"`
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// publicURL returns the absolute URL of path on this API, using
+// PUBLIC_BASE_URL when set and the request's host otherwise.
+func publicURL(c *gin.Context, path string) string {
+	base := strings.TrimRight(strings.TrimSpace(os.Getenv("PUBLIC_BASE_URL")), "/")
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return base + path
+}
+
+func (h *FeedsHandler) GetSharedFeedsHandler(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	sharedFeeds, err := h.db.GetSharedFeeds(c.Request.Context(), userId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch shared feeds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sharedFeeds)
+}
+
+// CreateSharedFeedHandler publishes a feed of the user's starred items, the
+// items of a label, their saved articles (optionally of one label) or their
+// public notes under a new unguessable token.
+func (h *FeedsHandler) CreateSharedFeedHandler(c *gin.Context) {
+	var req sharedFeedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	if req.Label != nil {
+		label := strings.TrimSpace(*req.Label)
+		req.Label = &label
+		if label == "" {
+			req.Label = nil
+		}
+	}
+
+	switch req.Source {
+	case sharedSourceStarred, sharedSourceNotes:
+		req.Label = nil
+	case sharedSourceLabel:
+		if req.Label == nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "label is required"})
+			return
+		}
+	case sharedSourceArticles:
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "source must be one of starred, label, articles or notes"})
+		return
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		switch {
+		case req.Source == sharedSourceStarred:
+			title = "Starred items"
+		case req.Source == sharedSourceNotes:
+			title = "Notes"
+		case req.Source == sharedSourceArticles && req.Label == nil:
+			title = "Saved articles"
+		default:
+			title = *req.Label
+		}
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create shared feed"})
+		return
+	}
+
+	sharedFeed := &models.SharedFeed{
+		Token:  token,
+		UserId: c.GetString("userId"),
+		Source: req.Source,
+		Label:  req.Label,
+		Title:  title,
+	}
+	if err := h.db.CreateSharedFeed(c.Request.Context(), sharedFeed); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create shared feed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"sharedFeed": sharedFeed,
+		"atomUrl":    publicURL(c, "/feeds/shared/"+token),
+		"rssUrl":     publicURL(c, "/feeds/shared/"+token+"?format="+syndication.FormatRSS),
+	})
+}
+
+func (h *FeedsHandler) DeleteSharedFeedHandler(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	if err := h.db.DeleteSharedFeed(c.Request.Context(), c.Param("token"), userId); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "shared feed not found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to delete shared feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "shared feed deleted successfully"})
+}
+
+// RenderSharedFeedHandler serves a shared feed as Atom, or as RSS with
+// ?format=rss. It is public, the token being the only credential.
+func (h *FeedsHandler) RenderSharedFeedHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	token := c.Param("token")
+
+	format := c.DefaultQuery("format", syndication.FormatAtom)
+	if format != syndication.FormatAtom && format != syndication.FormatRSS {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "format must be atom or rss"})
+		return
+	}
+
+	sharedFeed, err := h.db.GetSharedFeed(ctx, token)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "shared feed not found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch shared feed"})
+		return
+	}
+
+	var entries []syndication.Entry
+	switch sharedFeed.Source {
+	case sharedSourceArticles:
+		entries, err = h.sharedArticleEntries(c, sharedFeed)
+	case sharedSourceNotes:
+		entries, err = h.sharedNoteEntries(c, sharedFeed)
+	default:
+		entries, err = h.sharedItemEntries(c, sharedFeed)
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch shared feed"})
+		return
+	}
+
+	self := publicURL(c, "/feeds/shared/"+token)
+	if format == syndication.FormatRSS {
+		self += "?format=" + syndication.FormatRSS
+	}
+
+	feed := &syndication.Feed{
+		Id:      "urn:synthesis:shared:" + token,
+		Title:   sharedFeed.Title,
+		Link:    self,
+		Self:    self,
+		Updated: sharedFeed.CreatedAt,
+		Entries: entries,
+	}
+	for _, entry := range entries {
+		if entry.Updated.After(feed.Updated) {
+			feed.Updated = entry.Updated
+		}
+	}
+
+	c.Header("Content-Type", syndication.ContentType(format))
+	c.Status(http.StatusOK)
+	if err := syndication.Write(c.Writer, format, feed); err != nil {
+		c.Error(err)
+	}
+}
+
+func (h *FeedsHandler) sharedItemEntries(c *gin.Context, sharedFeed *models.SharedFeed) ([]syndication.Entry, error) {
+	filter := &models.FeedItemsFilter{Limit: sharedFeedLimit}
+	if sharedFeed.Source == sharedSourceStarred {
+		filter.StarredOnly = true
+	} else if sharedFeed.Label != nil {
+		filter.Label = *sharedFeed.Label
+	}
+
+	items, _, err := h.db.GetFeedItems(c.Request.Context(), sharedFeed.UserId, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]syndication.Entry, 0, len(items))
+	for _, item := range items {
+		entry := syndication.Entry{
+			Id:         fmt.Sprintf("urn:synthesis:item:%d", item.Id),
+			Title:      deref(item.Title),
+			Link:       deref(item.Link),
+			Summary:    deref(item.Description),
+			Content:    deref(item.Content),
+			Author:     deref(item.Author),
+			Categories: item.Categories,
+			Published:  item.CreatedAt,
+		}
+		if item.PublishedParsed != nil {
+			entry.Published = *item.PublishedParsed
+		}
+		// Reading or starring an item must not make it look updated
+		entry.Updated = entry.Published
+		if item.UpdatedParsed != nil {
+			entry.Updated = *item.UpdatedParsed
+		}
+		if entry.Author == "" && len(item.Authors) > 0 {
+			entry.Author = item.Authors[0].Name
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (h *FeedsHandler) sharedArticleEntries(c *gin.Context, sharedFeed *models.SharedFeed) ([]syndication.Entry, error) {
+	articles, err := h.db.GetArticles(c.Request.Context(), sharedFeed.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]syndication.Entry, 0, min(len(articles), sharedFeedLimit))
+	for _, article := range articles {
+		if sharedFeed.Label != nil && deref(article.Label) != *sharedFeed.Label {
+			continue
+		}
+
+		entry := syndication.Entry{
+			Id:        "urn:synthesis:article:" + deref(article.Id),
+			Title:     deref(article.Title),
+			Link:      article.URL,
+			Summary:   deref(article.Excerpt),
+			Content:   deref(article.Content),
+			Author:    deref(article.Author),
+			Published: article.ScrapedAt,
+			Updated:   article.ScrapedAt,
+		}
+		if article.PublishedTime != nil {
+			entry.Published = *article.PublishedTime
+		}
+		if article.Label != nil {
+			entry.Categories = []string{*article.Label}
+		}
+		entries = append(entries, entry)
+
+		if len(entries) == sharedFeedLimit {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+// sharedNoteEntries lists the user's public notes only, linking each one to
+// its public page.
+func (h *FeedsHandler) sharedNoteEntries(c *gin.Context, sharedFeed *models.SharedFeed) ([]syndication.Entry, error) {
+	notes, err := h.db.GetNotes(c.Request.Context(), sharedFeed.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	notes = slices.DeleteFunc(notes, func(note *models.Note) bool {
+		return !note.Public || note.Deleted || note.PublicId == nil
+	})
+	slices.SortFunc(notes, func(a, b *models.Note) int {
+		return cmp.Compare(b.UpdatedAt.UnixNano(), a.UpdatedAt.UnixNano())
+	})
+	if len(notes) > sharedFeedLimit {
+		notes = notes[:sharedFeedLimit]
+	}
+
+	entries := make([]syndication.Entry, 0, len(notes))
+	for _, note := range notes {
+		entries = append(entries, syndication.Entry{
+			Id:        "urn:synthesis:note:" + *note.PublicId,
+			Title:     note.Title,
+			Link:      publicURL(c, "/notes/public/"+*note.PublicId),
+			Content:   note.Content,
+			Published: note.CreatedAt,
+			Updated:   note.UpdatedAt,
+		})
+	}
+
+	return entries, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+
+	// How long finished jobs can still be polled
+	jobRetention = time.Hour
+)
+
+// job is work started by a request that outlives it, such as an OPML import
+// or a refresh, polled by the user through GET /feeds/jobs/:id.
+type job struct {
+	Id         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`
+	Completed  int        `json:"completed"`
+	Result     any        `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	userId     string
+}
+
+// jobRegistry keeps the jobs of every user in memory, they do not survive a
+// restart. Callers only ever get copies taken under mu, and change a job
+// through its id.
+type jobRegistry struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: make(map[string]*job)}
+}
+
+// startExclusive registers a running job of total steps for the user and
+// reports whether it did. When the user already has a job of kind running, a
+// copy of that one is returned instead. When they started one less than
+// cooldown ago, no job is returned, only how long is left to wait.
+func (r *jobRegistry) startExclusive(userId string, kind string, total int, cooldown time.Duration) (*job, bool, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var last *job
+	for _, j := range r.jobs {
+		if j.userId != userId || j.Kind != kind {
+			continue
+		}
+		if j.Status == jobRunning {
+			return j.snapshot(), false, 0, nil
+		}
+		if last == nil || j.CreatedAt.After(last.CreatedAt) {
+			last = j
+		}
+	}
+	if last != nil {
+		if wait := cooldown - time.Since(last.CreatedAt); wait > 0 {
+			return nil, false, wait, nil
+		}
+	}
+
+	j, err := r.add(userId, kind, total)
+	if err != nil {
+		return nil, false, 0, err
+	}
+	return j.snapshot(), true, 0, nil
+}
+
+// add registers a new job, r.mu must be held.
+func (r *jobRegistry) add(userId string, kind string, total int) (*job, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for id, j := range r.jobs {
+		if j.FinishedAt != nil && now.Sub(*j.FinishedAt) > jobRetention {
+			delete(r.jobs, id)
+		}
+	}
+
+	j := &job{
+		Id:        hex.EncodeToString(b),
+		Kind:      kind,
+		Status:    jobRunning,
+		Total:     total,
+		CreatedAt: now,
+		userId:    userId,
+	}
+	r.jobs[j.Id] = j
+	return j, nil
+}
+
+// advance records that one more step of the job completed.
+func (r *jobRegistry) advance(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if j, ok := r.jobs[id]; ok {
+		j.Completed++
+	}
+}
+
+// finish stores the outcome of the job, it failed when err is set. result
+// must not change afterwards, it is served as is.
+func (r *jobRegistry) finish(id string, result any, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j, ok := r.jobs[id]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	j.FinishedAt = &now
+	j.Result = result
+	j.Status = jobDone
+	if err != nil {
+		j.Status = jobFailed
+		j.Error = err.Error()
+	}
+}
+
+// get returns a copy of the job, which only its owner can see.
+func (r *jobRegistry) get(id string, userId string) *job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j, ok := r.jobs[id]
+	if !ok || j.userId != userId {
+		return nil
+	}
+	return j.snapshot()
+}
+
+// snapshot copies the job, the registry's mu must be held.
+func (j *job) snapshot() *job {
+	snapshot := *j
+	return &snapshot
+}
+
+// acceptJob answers a request that started j, a copy handed out by the
+// registry, with where to poll it.
+func acceptJob(c *gin.Context, j *job) {
+	c.Header("Location", "/feeds/jobs/"+j.Id)
+	c.JSON(http.StatusAccepted, j)
+}
+
+func (h *FeedsHandler) GetJobHandler(c *gin.Context) {
+	j := h.jobs.get(c.Param("id"), c.GetString("userId"))
+	if j == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, j)
+}
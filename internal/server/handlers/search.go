@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"synthesis/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	db database.Service
+}
+
+func NewSearchHandler(db database.Service) *SearchHandler {
+	return &SearchHandler{db: db}
+}
+
+func (h *SearchHandler) SearchHandler(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "q parameter is required"})
+		return
+	}
+
+	limit := 20
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = min(l, 100)
+		}
+	}
+
+	// Comma separated subset of feedItems, articles and notes
+	var types []string
+	if typesStr := c.Query("types"); typesStr != "" {
+		types = strings.Split(typesStr, ",")
+	}
+
+	userId := c.GetString("userId")
+
+	results, err := h.db.Search(c.Request.Context(), userId, query, types, limit)
+	if err != nil {
+		if errors.Is(err, database.ErrSearchUnavailable) {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to search"})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"synthesis/internal/database"
+	fetcher "synthesis/internal/services/feed-fetcher"
+	"synthesis/internal/services/websub"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Largest feed document a hub may push
+const maxWebSubBodySize = 5 << 20
+
+type WebSubHandler struct {
+	db database.Service
+}
+
+func NewWebSubHandler(db database.Service) *WebSubHandler {
+	return &WebSubHandler{db: db}
+}
+
+// VerifyHandler answers the hub confirming a subscribe or unsubscribe
+// request by echoing its challenge, or takes note of a denied subscription.
+func (h *WebSubHandler) VerifyHandler(c *gin.Context) {
+	callbackId := c.Param("id")
+	mode := c.Query("hub.mode")
+	topic := c.Query("hub.topic")
+
+	if mode == websub.ModeDenied {
+		if err := h.db.DenyWebSub(c.Request.Context(), callbackId, topic, c.Query("hub.reason")); err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+			return
+		}
+		c.Status(http.StatusOK)
+		return
+	}
+
+	challenge := c.Query("hub.challenge")
+	if (mode != websub.ModeSubscribe && mode != websub.ModeUnsubscribe) || topic == "" || challenge == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid verification request"})
+		return
+	}
+
+	leaseSeconds, _ := strconv.Atoi(c.Query("hub.lease_seconds"))
+
+	// Anything we cannot confirm must look unknown to the hub
+	if err := h.db.ConfirmWebSub(c.Request.Context(), callbackId, mode, topic, leaseSeconds); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	}
+
+	c.String(http.StatusOK, challenge)
+}
+
+// ContentHandler receives feed content pushed by the hub. Content for a
+// subscription the hub has not confirmed is rejected, content whose signature
+// does not match is acknowledged but dropped, as WebSub requires.
+// Storing happens after the response so slow extraction never makes the hub
+// retry.
+func (h *WebSubHandler) ContentHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	sub, err := h.db.GetWebSubSubscription(ctx, c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	}
+
+	// Only a hub that confirmed the subscription may deliver to it
+	if !database.IsWebSubVerified(sub, time.Now()) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "subscription is not verified"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebSubBodySize+1))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	if len(body) > maxWebSubBodySize {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "body too large"})
+		return
+	}
+
+	if !websub.VerifySignature(sub.Secret, c.GetHeader("X-Hub-Signature"), body) {
+		log.Printf("Dropping websub content for %s with an invalid signature", sub.FeedLink)
+		c.Status(http.StatusAccepted)
+		return
+	}
+
+	feed, err := fetcher.Parse(body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid feed"})
+		return
+	}
+
+	go func(ctx context.Context) {
+		newItems, err := h.db.IngestWebSubContent(ctx, sub.FeedLink, feed)
+		if err != nil {
+			log.Printf("Error storing websub content for %s: %v", sub.FeedLink, err)
+			return
+		}
+		log.Printf("Stored %d new items pushed for %s", newItems, sub.FeedLink)
+	}(context.WithoutCancel(ctx))
+
+	c.Status(http.StatusAccepted)
+}
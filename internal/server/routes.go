@@ -25,9 +25,9 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "X-Next-Cursor"},
 		AllowCredentials: true,
 	}))
 
@@ -37,6 +37,8 @@ func (s *Server) RegisterRoutes() http.Handler {
 	feedsHandler := handlers.NewFeedsHandler(s.db)
 	aiHandler := handlers.NewAiHandler(s.db)
 	emailHandler := handlers.NewEmailHandler(s.db)
+	searchHandler := handlers.NewSearchHandler(s.db)
+	webSubHandler := handlers.NewWebSubHandler(s.db)
 
 	router.GET("/", generalHandler.HelloWorldHandler)
 	router.GET("/health", generalHandler.HealthHandler)
@@ -49,6 +51,13 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	emails := router.Group("/emails")
 
+	search := router.Group("/search")
+
+	// Called by WebSub hubs, authenticated by the callback id and signature
+	websub := router.Group("/websub")
+	websub.GET("/callback/:id", webSubHandler.VerifyHandler)
+	websub.POST("/callback/:id", webSubHandler.ContentHandler)
+
 	notes.GET("/public/:public_id", notesHandler.GetPublicNoteHandler)
 
 	notes.Use(auth.AuthMiddleware())
@@ -68,13 +77,45 @@ func (s *Server) RegisterRoutes() http.Handler {
 		articles.DELETE("", articlesHandler.DeleteArticleHandler)
 	}
 
+	feeds.GET("/shared/:token", feedsHandler.RenderSharedFeedHandler)
+
 	feeds.Use(auth.AuthMiddleware())
 	{
 		feeds.POST("", feedsHandler.CreateFeedHandler)
 		feeds.GET("", feedsHandler.GetFeedItemsHandler)
 		feeds.DELETE("", feedsHandler.DeleteFeedHandler)
 		feeds.PUT("", feedsHandler.UpdateFeedItemHandler)
+		feeds.POST("/items/:id/save", feedsHandler.SaveFeedItemHandler)
 		feeds.PUT("/mark-all-read", feedsHandler.MarkAllFeedItemsAsReadHandler)
+		feeds.PUT("/mark-read", feedsHandler.MarkFeedItemsAsReadHandler)
+		feeds.GET("/stats", feedsHandler.GetFeedStatsHandler)
+		feeds.PUT("/update-frequency", feedsHandler.UpdateFeedFrequencyHandler)
+		feeds.GET("/broken", feedsHandler.GetBrokenFeedsHandler)
+		feeds.PUT("/reactivate", feedsHandler.ReactivateFeedHandler)
+		feeds.POST("/refresh", feedsHandler.RefreshFeedsHandler)
+		feeds.GET("/diagnostics", feedsHandler.DiagnoseFeedHandler)
+		feeds.GET("/opml", feedsHandler.ExportOPMLHandler)
+		feeds.POST("/opml", feedsHandler.ImportOPMLHandler)
+		feeds.GET("/jobs/:id", feedsHandler.GetJobHandler)
+		feeds.GET("/discover", feedsHandler.DiscoverFeedsHandler)
+		feeds.GET("/sources", feedsHandler.GetFeedSourcesHandler)
+		feeds.PATCH("/sources", feedsHandler.UpdateFeedSourceHandler)
+		feeds.GET("/retention", feedsHandler.GetRetentionPoliciesHandler)
+		feeds.PUT("/retention", feedsHandler.UpsertRetentionPolicyHandler)
+		feeds.DELETE("/retention", feedsHandler.DeleteRetentionPolicyHandler)
+		feeds.GET("/rules", feedsHandler.GetFeedRulesHandler)
+		feeds.POST("/rules", feedsHandler.CreateFeedRuleHandler)
+		feeds.POST("/rules/dry-run", feedsHandler.DryRunFeedRuleHandler)
+		feeds.PUT("/rules/:id", feedsHandler.UpdateFeedRuleHandler)
+		feeds.DELETE("/rules/:id", feedsHandler.DeleteFeedRuleHandler)
+		feeds.GET("/shared", feedsHandler.GetSharedFeedsHandler)
+		feeds.POST("/shared", feedsHandler.CreateSharedFeedHandler)
+		feeds.DELETE("/shared/:token", feedsHandler.DeleteSharedFeedHandler)
+	}
+
+	search.Use(auth.AuthMiddleware())
+	{
+		search.GET("", searchHandler.SearchHandler)
 	}
 
 	ai := router.Group("/ai")
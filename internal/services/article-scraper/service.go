@@ -1,12 +1,16 @@
 package scraper
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"synthesis/internal/models"
 	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	readability "github.com/go-shiori/go-readability"
 )
 
@@ -44,6 +48,11 @@ func toPointer(s string) *string {
 }
 
 func GetArticle(urlStr string) (models.Article, error) {
+	return GetArticleContext(context.Background(), urlStr)
+}
+
+// GetArticleContext is GetArticle with a context bounding the download.
+func GetArticleContext(ctx context.Context, urlStr string) (models.Article, error) {
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
 		return models.Article{}, fmt.Errorf("failed to parse URL: %v", err)
@@ -56,7 +65,7 @@ func GetArticle(urlStr string) (models.Article, error) {
 		return models.Article{}, fmt.Errorf("failed to create request: %v", err)
 	}
 
-	resp, err := client.Do(req)
+	resp, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		return models.Article{}, fmt.Errorf("failed to fetch URL: %v", err)
 	}
@@ -90,3 +99,50 @@ func GetArticle(urlStr string) (models.Article, error) {
 
 	return article, nil
 }
+
+// Only the head of a page is needed to find its preview image
+const maxPageImageBodySize = 1 << 20
+
+// GetPageImage returns the preview image a page advertises through its
+// og:image or twitter:image meta tags, resolved against the page URL.
+func GetPageImage(ctx context.Context, urlStr string) (string, error) {
+	req, err := buildRequest(urlStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := createHTTPClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch URL: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, maxPageImageBodySize))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse page: %v", err)
+	}
+
+	for _, selector := range []string{
+		`meta[property="og:image:secure_url"]`,
+		`meta[property="og:image"]`,
+		`meta[name="og:image"]`,
+		`meta[name="twitter:image"]`,
+		`meta[property="twitter:image"]`,
+	} {
+		content := strings.TrimSpace(doc.Find(selector).First().AttrOr("content", ""))
+		if content == "" {
+			continue
+		}
+		// Redirects may have moved us, relative images resolve against the final URL
+		image, err := resp.Request.URL.Parse(content)
+		if err == nil && (image.Scheme == "http" || image.Scheme == "https") {
+			return image.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("no preview image found")
+}
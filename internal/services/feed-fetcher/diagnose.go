@@ -0,0 +1,121 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"synthesis/internal/services/websub"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Redirect is one hop of a redirect chain: URL answered Status and sent us
+// to Location.
+type Redirect struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Location string `json:"location"`
+}
+
+// Diagnosis is what a single unconditional fetch of a feed looked like.
+type Diagnosis struct {
+	Status        int
+	Redirects     []Redirect
+	FinalURL      string
+	ContentType   string
+	ContentLength int
+	// Detected from the document even when it fails to parse
+	FeedType   string
+	Feed       *gofeed.Feed
+	Hub        string
+	FetchError string
+	ParseError string
+	Duration   time.Duration
+}
+
+// createRecordingHTTPClient returns a client that appends every redirect it
+// follows to redirects.
+func createRecordingHTTPClient(redirects *[]Redirect) *http.Client {
+	client := createHTTPClient()
+	checkRedirect := client.CheckRedirect
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		*redirects = append(*redirects, Redirect{
+			URL:      via[len(via)-1].URL.String(),
+			Status:   req.Response.StatusCode,
+			Location: req.URL.String(),
+		})
+		return checkRedirect(req, via)
+	}
+	return client
+}
+
+// Diagnose fetches feedURL without validators and reports each step, down to
+// parse errors, instead of failing on the first problem. It only returns an
+// error when feedURL is not a valid URL.
+func Diagnose(ctx context.Context, feedURL string) (*Diagnosis, error) {
+	if parsed, err := url.Parse(feedURL); err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid URL: %s", feedURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8")
+
+	diagnosis := &Diagnosis{Redirects: make([]Redirect, 0)}
+	start := time.Now()
+	defer func() { diagnosis.Duration = time.Since(start) }()
+
+	resp, err := createRecordingHTTPClient(&diagnosis.Redirects).Do(req)
+	if err != nil {
+		diagnosis.FetchError = err.Error()
+		return diagnosis, nil
+	}
+	defer resp.Body.Close()
+
+	diagnosis.Status = resp.StatusCode
+	diagnosis.FinalURL = resp.Request.URL.String()
+	diagnosis.ContentType = resp.Header.Get("Content-Type")
+
+	body, err := readFeedBody(resp.Body)
+	diagnosis.ContentLength = len(body)
+	if err != nil {
+		diagnosis.FetchError = err.Error()
+		return diagnosis, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		diagnosis.FetchError = fmt.Sprintf("received non-2xx status code: %d", resp.StatusCode)
+		return diagnosis, nil
+	}
+
+	diagnosis.FeedType = feedTypeName(gofeed.DetectFeedType(bytes.NewReader(body)))
+
+	feed, err := Parse(body)
+	if err != nil {
+		diagnosis.ParseError = err.Error()
+		return diagnosis, nil
+	}
+	diagnosis.Feed = feed
+	diagnosis.Hub, _ = websub.DiscoverLinks(resp.Header, body, resp.Request.URL)
+
+	return diagnosis, nil
+}
+
+func feedTypeName(feedType gofeed.FeedType) string {
+	switch feedType {
+	case gofeed.FeedTypeRSS:
+		return "rss"
+	case gofeed.FeedTypeAtom:
+		return "atom"
+	case gofeed.FeedTypeJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
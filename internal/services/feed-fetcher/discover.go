@@ -0,0 +1,153 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const maxDiscoveryBodySize = 2 << 20
+
+// Paths probed when a page does not advertise its feeds
+var commonFeedPaths = []string{
+	"/feed",
+	"/rss.xml",
+	"/atom.xml",
+	"/feed.xml",
+	"/index.xml",
+	"/rss",
+	"/feed.json",
+}
+
+var feedMimeTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/rdf+xml":   true,
+	"application/feed+json": true,
+	"application/json":      true,
+	"text/xml":              true,
+	"application/xml":       true,
+}
+
+type Candidate struct {
+	FeedLink string `json:"feedLink"`
+	Title    string `json:"title,omitempty"`
+	FeedType string `json:"feedType,omitempty"`
+}
+
+// Discover finds the feeds of a website. If pageURL is already a feed it is
+// returned as the only candidate, otherwise the page's <link rel="alternate">
+// tags are used, falling back to probing common feed paths. Only candidates
+// that parse as feeds are returned.
+func Discover(ctx context.Context, pageURL string) ([]Candidate, error) {
+	// Users often paste bare domains such as "example.com"
+	if !strings.Contains(pageURL, "://") {
+		pageURL = "https://" + pageURL
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid URL: %s", pageURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+
+	resp, err := createHTTPClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("received non-2xx status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDiscoveryBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Redirects may have moved us, relative links resolve against the final URL
+	base = resp.Request.URL
+
+	if feed, err := newParser().Parse(bytes.NewReader(body)); err == nil {
+		return []Candidate{{FeedLink: base.String(), Title: feed.Title, FeedType: feed.FeedType}}, nil
+	}
+
+	links := advertisedFeeds(body, base)
+	if len(links) == 0 {
+		for _, path := range commonFeedPaths {
+			links = append(links, base.ResolveReference(&url.URL{Path: path}).String())
+		}
+	}
+
+	return validateCandidates(ctx, links), nil
+}
+
+func advertisedFeeds(body []byte, base *url.URL) []string {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var links []string
+	seen := make(map[string]bool)
+
+	doc.Find(`link[rel~="alternate"][href]`).Each(func(_ int, s *goquery.Selection) {
+		mimeType := strings.ToLower(strings.TrimSpace(s.AttrOr("type", "")))
+		if !feedMimeTypes[mimeType] {
+			return
+		}
+
+		href, err := base.Parse(strings.TrimSpace(s.AttrOr("href", "")))
+		if err != nil || seen[href.String()] {
+			return
+		}
+		seen[href.String()] = true
+		links = append(links, href.String())
+	})
+
+	return links
+}
+
+// validateCandidates fetches every link concurrently and keeps the ones that
+// parse as feeds, preserving the original order.
+func validateCandidates(ctx context.Context, links []string) []Candidate {
+	results := make([]*Candidate, len(links))
+
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		go func(i int, link string) {
+			defer wg.Done()
+
+			result, err := Fetch(ctx, link, "", "")
+			if err != nil || result.Feed == nil {
+				return
+			}
+			results[i] = &Candidate{FeedLink: link, Title: result.Feed.Title, FeedType: result.Feed.FeedType}
+		}(i, link)
+	}
+	wg.Wait()
+
+	candidates := make([]Candidate, 0)
+	for _, candidate := range results {
+		if candidate != nil {
+			candidates = append(candidates, *candidate)
+		}
+	}
+
+	return candidates
+}
@@ -0,0 +1,47 @@
+package fetcher
+
+import (
+	"strconv"
+
+	"github.com/mmcdole/gofeed"
+	jsonfeed "github.com/mmcdole/gofeed/json"
+)
+
+func newParser() *gofeed.Parser {
+	parser := gofeed.NewParser()
+	parser.JSONTranslator = &jsonTranslator{}
+	return parser
+}
+
+// jsonTranslator fixes the enclosures of JSON Feed items, which gofeed fills
+// with the attachment's duration where the other formats carry its size.
+type jsonTranslator struct {
+	gofeed.DefaultJSONTranslator
+}
+
+func (t *jsonTranslator) Translate(feed interface{}) (*gofeed.Feed, error) {
+	result, err := t.DefaultJSONTranslator.Translate(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	source, ok := feed.(*jsonfeed.Feed)
+	if !ok || len(source.Items) != len(result.Items) {
+		return result, nil
+	}
+
+	for i, item := range source.Items {
+		if item.Attachments == nil || len(*item.Attachments) != len(result.Items[i].Enclosures) {
+			continue
+		}
+		for j, attachment := range *item.Attachments {
+			length := ""
+			if attachment.SizeInBytes > 0 {
+				length = strconv.FormatInt(attachment.SizeInBytes, 10)
+			}
+			result.Items[i].Enclosures[j].Length = length
+		}
+	}
+
+	return result, nil
+}
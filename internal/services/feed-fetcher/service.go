@@ -0,0 +1,145 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"synthesis/internal/services/websub"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+const userAgent = "Synthesis/1.0 (+feed reader)"
+
+// Largest feed document read, anything bigger is treated as broken
+const maxFeedBodySize = 10 << 20
+
+type Result struct {
+	Feed         *gofeed.Feed
+	NotModified  bool
+	ETag         string
+	LastModified string
+	// WebSub hub the feed advertises and the topic URL to subscribe to
+	Hub  string
+	Self string
+	// URL the feed moved to, when the request was permanently redirected
+	PermanentRedirect string
+}
+
+func createHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: 60 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// Fetch downloads and parses the feed at feedURL. When etag or lastModified
+// are set they are sent as validators, and a 304 response is reported through
+// Result.NotModified with a nil Feed. Permanent redirects are followed and
+// reported through Result.PermanentRedirect.
+func Fetch(ctx context.Context, feedURL string, etag string, lastModified string) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8")
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	var redirects []Redirect
+	resp, err := createRecordingHTTPClient(&redirects).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	result := &Result{
+		ETag:              resp.Header.Get("ETag"),
+		LastModified:      resp.Header.Get("Last-Modified"),
+		PermanentRedirect: permanentRedirect(feedURL, redirects),
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		// Servers are not required to repeat validators on a 304
+		if result.ETag == "" {
+			result.ETag = etag
+		}
+		if result.LastModified == "" {
+			result.LastModified = lastModified
+		}
+		result.NotModified = true
+		return result, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("received non-2xx status code: %d", resp.StatusCode)
+	}
+
+	body, err := readFeedBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	feed, err := Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	result.Feed = feed
+	result.Hub, result.Self = websub.DiscoverLinks(resp.Header, body, resp.Request.URL)
+
+	return result, nil
+}
+
+// permanentRedirect returns where the permanent redirects at the start of
+// the chain lead. A temporary redirect ends the chain, since the URL it
+// redirects from is the one to keep.
+func permanentRedirect(feedURL string, redirects []Redirect) string {
+	target := feedURL
+	for _, redirect := range redirects {
+		if redirect.Status != http.StatusMovedPermanently && redirect.Status != http.StatusPermanentRedirect {
+			break
+		}
+		target = redirect.Location
+	}
+
+	if target == feedURL {
+		return ""
+	}
+	return target
+}
+
+// readFeedBody reads a feed document of at most maxFeedBodySize bytes.
+func readFeedBody(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxFeedBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+	if len(body) > maxFeedBodySize {
+		return nil, fmt.Errorf("feed is larger than %d bytes", maxFeedBodySize)
+	}
+	return body, nil
+}
+
+// Parse parses a feed document of any supported format.
+func Parse(body []byte) (*gofeed.Feed, error) {
+	feed, err := newParser().Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	}
+	return feed, nil
+}
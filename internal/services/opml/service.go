@@ -0,0 +1,158 @@
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Document struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type Body struct {
+	Outlines []Outline `xml:"outline"`
+}
+
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Category string    `xml:"category,attr,omitempty"`
+	Outlines []Outline `xml:"outline"`
+}
+
+// Subscription is a single feed found in or written to an OPML document.
+type Subscription struct {
+	FeedLink string `json:"feedLink"`
+	Link     string `json:"link,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Label    string `json:"label,omitempty"`
+}
+
+// Parse reads an OPML document and flattens its outlines into subscriptions.
+// The label of a feed is its category attribute when present, otherwise the
+// text of the closest parent outline.
+func Parse(r io.Reader) ([]Subscription, error) {
+	var doc Document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OPML: %w", err)
+	}
+
+	var subscriptions []Subscription
+	seen := make(map[string]bool)
+
+	var walk func(outlines []Outline, parent string)
+	walk = func(outlines []Outline, parent string) {
+		for _, outline := range outlines {
+			if outline.XMLURL == "" {
+				walk(outline.Outlines, outlineName(outline))
+				continue
+			}
+
+			if seen[outline.XMLURL] {
+				continue
+			}
+			seen[outline.XMLURL] = true
+
+			label := categoryLabel(outline.Category)
+			if label == "" {
+				label = parent
+			}
+
+			subscriptions = append(subscriptions, Subscription{
+				FeedLink: outline.XMLURL,
+				Link:     outline.HTMLURL,
+				Title:    outlineName(outline),
+				Label:    label,
+			})
+		}
+	}
+	walk(doc.Body.Outlines, "")
+
+	return subscriptions, nil
+}
+
+// Write renders subscriptions as an OPML 2.0 document, grouping labelled
+// feeds under one outline per label.
+func Write(w io.Writer, title string, subscriptions []Subscription) error {
+	doc := Document{
+		Version: "2.0",
+		Head: Head{
+			Title:       title,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	groups := make(map[string][]Outline)
+	for _, subscription := range subscriptions {
+		name := subscription.Title
+		if name == "" {
+			name = subscription.FeedLink
+		}
+
+		outline := Outline{
+			Text:    name,
+			Title:   name,
+			Type:    "rss",
+			XMLURL:  subscription.FeedLink,
+			HTMLURL: subscription.Link,
+		}
+
+		if subscription.Label == "" {
+			doc.Body.Outlines = append(doc.Body.Outlines, outline)
+			continue
+		}
+		groups[subscription.Label] = append(groups[subscription.Label], outline)
+	}
+
+	labels := make([]string, 0, len(groups))
+	for label := range groups {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		doc.Body.Outlines = append(doc.Body.Outlines, Outline{
+			Text:     label,
+			Title:    label,
+			Outlines: groups[label],
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
+
+func outlineName(outline Outline) string {
+	if outline.Title != "" {
+		return outline.Title
+	}
+	return outline.Text
+}
+
+// categoryLabel turns a category attribute such as "/Tech/Go,/News" into the
+// last segment of its first path.
+func categoryLabel(category string) string {
+	first := strings.TrimSpace(strings.Split(category, ",")[0])
+	segments := strings.Split(strings.Trim(first, "/"), "/")
+	return strings.TrimSpace(segments[len(segments)-1])
+}
@@ -0,0 +1,174 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"synthesis/internal/models"
+)
+
+const (
+	FieldTitle       = "title"
+	FieldDescription = "description"
+	FieldAuthor      = "author"
+	FieldFeed        = "feed"
+	FieldAny         = "any"
+
+	MatchKeyword = "keyword"
+	MatchRegex   = "regex"
+
+	ActionRead  = "read"
+	ActionStar  = "star"
+	ActionLabel = "label"
+	ActionDrop  = "drop"
+)
+
+// Item is the part of a feed item rules are matched against.
+type Item struct {
+	FeedLink    string
+	FeedTitle   string
+	Title       string
+	Description string
+	Content     string
+	Author      string
+}
+
+// Outcome is the combined effect of every rule matching an item.
+type Outcome struct {
+	Drop  bool
+	Read  bool
+	Star  bool
+	Label string
+	Rules []int64
+}
+
+// Rule is a compiled user rule.
+type Rule struct {
+	*models.FeedRule
+	regex    *regexp.Regexp
+	keywords []string
+}
+
+// Validate checks a rule before it is stored, so compiling stored rules
+// never fails.
+func Validate(rule *models.FeedRule) error {
+	_, err := Compile(rule)
+	return err
+}
+
+// Compile prepares rule for matching. Keyword patterns are comma separated
+// and matched case-insensitively, an empty pattern matches every item in the
+// rule's scope.
+func Compile(rule *models.FeedRule) (*Rule, error) {
+	switch rule.Field {
+	case FieldTitle, FieldDescription, FieldAuthor, FieldFeed, FieldAny:
+	default:
+		return nil, fmt.Errorf("invalid rule field: %s", rule.Field)
+	}
+
+	switch rule.Action {
+	case ActionRead, ActionStar, ActionDrop:
+	case ActionLabel:
+		if rule.Label == nil || strings.TrimSpace(*rule.Label) == "" {
+			return nil, fmt.Errorf("label rules need a label")
+		}
+	default:
+		return nil, fmt.Errorf("invalid rule action: %s", rule.Action)
+	}
+
+	compiled := &Rule{FeedRule: rule}
+
+	switch rule.MatchType {
+	case MatchKeyword:
+		for _, keyword := range strings.Split(rule.Pattern, ",") {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+				compiled.keywords = append(compiled.keywords, keyword)
+			}
+		}
+	case MatchRegex:
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule pattern: %v", err)
+		}
+		compiled.regex = regex
+	default:
+		return nil, fmt.Errorf("invalid rule match type: %s", rule.MatchType)
+	}
+
+	return compiled, nil
+}
+
+// Matches reports whether the rule applies to item.
+func (r *Rule) Matches(item Item) bool {
+	if !r.Enabled {
+		return false
+	}
+	if r.FeedLink != nil && *r.FeedLink != "" && *r.FeedLink != item.FeedLink {
+		return false
+	}
+
+	var values []string
+	switch r.Field {
+	case FieldTitle:
+		values = []string{item.Title}
+	case FieldDescription:
+		values = []string{item.Description}
+	case FieldAuthor:
+		values = []string{item.Author}
+	case FieldFeed:
+		values = []string{item.FeedTitle, item.FeedLink}
+	case FieldAny:
+		values = []string{item.Title, item.Description, item.Content, item.Author}
+	}
+
+	for _, value := range values {
+		if r.matchesValue(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesValue(value string) bool {
+	if r.regex != nil {
+		return r.regex.MatchString(value)
+	}
+	if len(r.keywords) == 0 {
+		return true
+	}
+
+	value = strings.ToLower(value)
+	for _, keyword := range r.keywords {
+		if strings.Contains(value, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate applies rules in order. Dropping wins over every other action and
+// the first matching label rule sets the label.
+func Evaluate(rules []*Rule, item Item) Outcome {
+	var outcome Outcome
+	for _, rule := range rules {
+		if !rule.Matches(item) {
+			continue
+		}
+
+		outcome.Rules = append(outcome.Rules, rule.Id)
+		switch rule.Action {
+		case ActionDrop:
+			outcome.Drop = true
+		case ActionRead:
+			outcome.Read = true
+		case ActionStar:
+			outcome.Star = true
+		case ActionLabel:
+			if outcome.Label == "" {
+				outcome.Label = strings.TrimSpace(*rule.Label)
+			}
+		}
+	}
+
+	return outcome
+}
@@ -0,0 +1,230 @@
+package syndication
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+const (
+	FormatAtom = "atom"
+	FormatRSS  = "rss"
+)
+
+// Feed is a feed to publish, independent of its format.
+type Feed struct {
+	Id          string
+	Title       string
+	Description string
+	// Link is the page the feed belongs to, Self the URL it is served at
+	Link    string
+	Self    string
+	Updated time.Time
+	Entries []Entry
+}
+
+type Entry struct {
+	Id         string
+	Title      string
+	Link       string
+	Summary    string
+	Content    string
+	Author     string
+	Categories []string
+	Published  time.Time
+	Updated    time.Time
+}
+
+// ContentType returns the media type of format.
+func ContentType(format string) string {
+	if format == FormatRSS {
+		return "application/rss+xml; charset=utf-8"
+	}
+	return "application/atom+xml; charset=utf-8"
+}
+
+// Write renders feed as an Atom or RSS 2.0 document.
+func Write(w io.Writer, format string, feed *Feed) error {
+	var doc any
+	if format == FormatRSS {
+		doc = rssDocument(feed)
+	} else {
+		doc = atomDocument(feed)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Id         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Author     *atomPerson    `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary"`
+	Content    *atomText      `xml:"content"`
+}
+
+func atomDocument(feed *Feed) *atomFeed {
+	doc := &atomFeed{
+		Id:      feed.Id,
+		Title:   feed.Title,
+		Updated: feed.Updated.UTC().Format(time.RFC3339),
+	}
+	if feed.Link != "" {
+		doc.Links = append(doc.Links, atomLink{Rel: "alternate", Href: feed.Link})
+	}
+	if feed.Self != "" {
+		doc.Links = append(doc.Links, atomLink{Rel: "self", Type: ContentType(FormatAtom), Href: feed.Self})
+	}
+
+	for _, entry := range feed.Entries {
+		atom := atomEntry{
+			Id:      entry.Id,
+			Title:   entry.Title,
+			Updated: entry.Updated.UTC().Format(time.RFC3339),
+		}
+		if !entry.Published.IsZero() {
+			atom.Published = entry.Published.UTC().Format(time.RFC3339)
+		}
+		if entry.Link != "" {
+			atom.Links = append(atom.Links, atomLink{Rel: "alternate", Href: entry.Link})
+		}
+		if entry.Author != "" {
+			atom.Author = &atomPerson{Name: entry.Author}
+		}
+		for _, category := range entry.Categories {
+			atom.Categories = append(atom.Categories, atomCategory{Term: category})
+		}
+		if entry.Summary != "" {
+			atom.Summary = &atomText{Type: "html", Body: entry.Summary}
+		}
+		if entry.Content != "" {
+			atom.Content = &atomText{Type: "html", Body: entry.Content}
+		}
+		doc.Entries = append(doc.Entries, atom)
+	}
+
+	return doc
+}
+
+type rssDocumentRoot struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Content string     `xml:"xmlns:content,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          *rssSelf  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssSelf struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link,omitempty"`
+	GUID        rssGUID  `xml:"guid"`
+	Description string   `xml:"description,omitempty"`
+	Content     string   `xml:"content:encoded,omitempty"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+}
+
+func rssDocument(feed *Feed) *rssDocumentRoot {
+	doc := &rssDocumentRoot{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Content: "http://purl.org/rss/1.0/modules/content/",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         feed.Title,
+			Link:          feed.Link,
+			Description:   feed.Description,
+			LastBuildDate: feed.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+	if doc.Channel.Description == "" {
+		doc.Channel.Description = feed.Title
+	}
+	if feed.Self != "" {
+		doc.Channel.Self = &rssSelf{Rel: "self", Type: ContentType(FormatRSS), Href: feed.Self}
+	}
+
+	for _, entry := range feed.Entries {
+		item := rssItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			GUID:        rssGUID{Value: entry.Id},
+			Description: entry.Summary,
+			Content:     entry.Content,
+			// RSS authors must be email addresses, names go to dc:creator
+			Creator:    entry.Author,
+			Categories: entry.Categories,
+		}
+		if item.Description == "" {
+			item.Description = entry.Content
+		}
+		published := entry.Published
+		if published.IsZero() {
+			published = entry.Updated
+		}
+		item.PubDate = published.UTC().Format(time.RFC1123Z)
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	return doc
+}
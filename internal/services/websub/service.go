@@ -0,0 +1,267 @@
+package websub
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// Lease asked for, hubs are free to grant a different one
+	DefaultLeaseSeconds = 10 * 24 * 60 * 60
+
+	ModeSubscribe   = "subscribe"
+	ModeUnsubscribe = "unsubscribe"
+	ModeDenied      = "denied"
+)
+
+func createHTTPClient() *http.Client {
+	return &http.Client{Timeout: 15 * time.Second}
+}
+
+// Enabled reports whether hubs can reach us, which requires PUBLIC_BASE_URL
+// to be set to the public address of the API.
+func Enabled() bool {
+	return publicBaseURL() != ""
+}
+
+func publicBaseURL() string {
+	return strings.TrimRight(strings.TrimSpace(os.Getenv("PUBLIC_BASE_URL")), "/")
+}
+
+// CallbackURL returns the public URL hubs deliver to for a subscription.
+func CallbackURL(callbackId string) string {
+	return publicBaseURL() + "/websub/callback/" + url.PathEscape(callbackId)
+}
+
+// NewToken returns a random hex token, used for callback ids and secrets.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Subscribe asks hub to deliver topic to callback, signing content with
+// secret. The hub confirms asynchronously by calling the callback.
+func Subscribe(ctx context.Context, hub, topic, callback, secret string, leaseSeconds int) error {
+	form := url.Values{
+		"hub.mode":     {ModeSubscribe},
+		"hub.topic":    {topic},
+		"hub.callback": {callback},
+		"hub.secret":   {secret},
+	}
+	if leaseSeconds > 0 {
+		form.Set("hub.lease_seconds", fmt.Sprint(leaseSeconds))
+	}
+	return request(ctx, hub, form)
+}
+
+// Unsubscribe asks hub to stop delivering topic to callback.
+func Unsubscribe(ctx context.Context, hub, topic, callback string) error {
+	return request(ctx, hub, url.Values{
+		"hub.mode":     {ModeUnsubscribe},
+		"hub.topic":    {topic},
+		"hub.callback": {callback},
+	})
+}
+
+func request(ctx context.Context, hub string, form url.Values) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := createHTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach hub: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("hub rejected %s request with status %d: %s", form.Get("hub.mode"), resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// VerifySignature checks the X-Hub-Signature header of delivered content,
+// of the form "method=hexdigest", against secret.
+func VerifySignature(secret string, signature string, body []byte) bool {
+	method, digest, ok := strings.Cut(strings.TrimSpace(signature), "=")
+	if !ok {
+		return false
+	}
+
+	var newHash func() hash.Hash
+	switch strings.ToLower(method) {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha384":
+		newHash = sha512.New384
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return false
+	}
+
+	expected, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// DiscoverLinks returns the hub and self URLs a feed advertises, from its
+// Link headers first and then from the document itself: <link rel="hub">
+// elements of RSS and Atom feeds, or the hubs of a JSON Feed.
+func DiscoverLinks(header http.Header, body []byte, base *url.URL) (hub string, self string) {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, rel := parseLinkHeader(link)
+			switch {
+			case hub == "" && rel["hub"]:
+				hub = target
+			case self == "" && rel["self"]:
+				self = target
+			}
+		}
+	}
+
+	if hub == "" || self == "" {
+		docHub, docSelf := documentLinks(body)
+		if hub == "" {
+			hub = docHub
+		}
+		if self == "" {
+			self = docSelf
+		}
+	}
+
+	return resolve(base, hub), resolve(base, self)
+}
+
+// parseLinkHeader parses one `<url>; rel="a b"` entry of a Link header.
+func parseLinkHeader(link string) (string, map[string]bool) {
+	parts := strings.Split(link, ";")
+	target := strings.TrimSpace(parts[0])
+	if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+		return "", nil
+	}
+
+	rels := make(map[string]bool)
+	for _, param := range parts[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || strings.ToLower(strings.TrimSpace(key)) != "rel" {
+			continue
+		}
+		for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(value), `"`)) {
+			rels[strings.ToLower(rel)] = true
+		}
+	}
+
+	return strings.Trim(target, "<>"), rels
+}
+
+func documentLinks(body []byte) (hub string, self string) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var feed struct {
+			FeedURL string `json:"feed_url"`
+			Hubs    []struct {
+				Type string `json:"type"`
+				URL  string `json:"url"`
+			} `json:"hubs"`
+		}
+		if err := json.Unmarshal(trimmed, &feed); err != nil {
+			return "", ""
+		}
+		for _, h := range feed.Hubs {
+			if strings.EqualFold(h.Type, "websub") || strings.EqualFold(h.Type, "pubsubhubbub") {
+				return h.URL, feed.FeedURL
+			}
+		}
+		return "", feed.FeedURL
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(trimmed))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return hub, self
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch element.Name.Local {
+		// Links of the entries are not the feed's
+		case "item", "entry":
+			return hub, self
+		case "link":
+			var href string
+			rels := make(map[string]bool)
+			for _, attr := range element.Attr {
+				switch attr.Name.Local {
+				case "href":
+					href = strings.TrimSpace(attr.Value)
+				case "rel":
+					for _, rel := range strings.Fields(attr.Value) {
+						rels[strings.ToLower(rel)] = true
+					}
+				}
+			}
+			if href == "" {
+				continue
+			}
+			if hub == "" && rels["hub"] {
+				hub = href
+			}
+			if self == "" && rels["self"] {
+				self = href
+			}
+		}
+	}
+}
+
+func resolve(base *url.URL, link string) string {
+	if link == "" {
+		return ""
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		parsed = base.ResolveReference(parsed)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return ""
+	}
+	return parsed.String()
+}